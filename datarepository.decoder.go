@@ -0,0 +1,133 @@
+// datarepository.decoder.go
+
+package datarepository
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// DecoderOptions configures how stored JSON documents are decoded on Read
+type DecoderOptions struct {
+	// UseNumber decodes numbers into interface{} values as json.Number instead of float64,
+	// so that large integer IDs are not corrupted.
+	UseNumber bool
+	// DisallowUnknownFields fails decoding if a document has fields the target struct lacks
+	DisallowUnknownFields bool
+	// TimeFormats are additional layouts accepted for time.Time fields, tried in order
+	// when a value is not valid RFC 3339.
+	TimeFormats []string
+}
+
+func (o DecoderOptions) decode(data []byte, value interface{}) error {
+	if target := reflect.ValueOf(value); target.Kind() != reflect.Ptr || target.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(value)}
+	}
+	if len(o.TimeFormats) > 0 {
+		normalized, err := o.normalizeTimeFormats(data, reflect.TypeOf(value))
+		if err != nil {
+			return err
+		}
+		data = normalized
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if o.UseNumber {
+		decoder.UseNumber()
+	}
+	if o.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(value)
+}
+
+// normalizeTimeFormats rewrites the values that will be decoded into time.Time fields of
+// target to RFC 3339, the only format encoding/json accepts. Other values are left untouched.
+func (o DecoderOptions) normalizeTimeFormats(data []byte, target reflect.Type) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(o.normalizeTimes(generic, target))
+}
+
+func (o DecoderOptions) normalizeTimes(value interface{}, target reflect.Type) interface{} {
+	for target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+
+	if target == timeType {
+		s, ok := value.(string)
+		if !ok {
+			return value
+		}
+		if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return s
+		}
+		for _, layout := range o.TimeFormats {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.Format(time.RFC3339Nano)
+			}
+		}
+		return value
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		if doc, ok := value.(map[string]interface{}); ok {
+			o.normalizeStructFields(doc, target)
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for i := range items {
+				items[i] = o.normalizeTimes(items[i], target.Elem())
+			}
+		}
+	case reflect.Map:
+		if doc, ok := value.(map[string]interface{}); ok {
+			for key := range doc {
+				doc[key] = o.normalizeTimes(doc[key], target.Elem())
+			}
+		}
+	}
+	return value
+}
+
+func (o DecoderOptions) normalizeStructFields(doc map[string]interface{}, target reflect.Type) {
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				o.normalizeStructFields(doc, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		// encoding/json matches keys case-insensitively and decodes every matching key
+		for key, value := range doc {
+			if strings.EqualFold(key, name) {
+				doc[key] = o.normalizeTimes(value, field.Type)
+			}
+		}
+	}
+}
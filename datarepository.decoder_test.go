@@ -0,0 +1,120 @@
+// datarepository.decoder_test.go
+
+package datarepository
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type decoderAudit struct {
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type decoderEvent struct {
+	decoderAudit
+	*DecoderTestOwner
+	Name     string      `json:"name"`
+	At       time.Time   `json:"at"`
+	Deadline *time.Time  `json:"deadline"`
+	History  []time.Time `json:"history"`
+	Skipped  time.Time   `json:"-"`
+	Untagged time.Time
+}
+
+type DecoderTestOwner struct {
+	OwnedAt time.Time `json:"ownedAt"`
+}
+
+func TestDecoderOptionsUseNumber(t *testing.T) {
+	data := []byte(`{"id":9007199254740993}`)
+
+	var plain map[string]interface{}
+	if err := (DecoderOptions{}).decode(data, &plain); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if _, ok := plain["id"].(float64); !ok {
+		t.Errorf("expected float64 without UseNumber, got %T", plain["id"])
+	}
+
+	var numbered map[string]interface{}
+	if err := (DecoderOptions{UseNumber: true}).decode(data, &numbered); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	number, ok := numbered["id"].(json.Number)
+	if !ok {
+		t.Fatalf("expected json.Number with UseNumber, got %T", numbered["id"])
+	}
+	if id, err := number.Int64(); err != nil || id != 9007199254740993 {
+		t.Errorf("id = %v, %v; want 9007199254740993", id, err)
+	}
+}
+
+func TestDecoderOptionsDisallowUnknownFields(t *testing.T) {
+	data := []byte(`{"name":"launch","extra":true}`)
+
+	var event decoderEvent
+	if err := (DecoderOptions{}).decode(data, &event); err != nil || event.Name != "launch" {
+		t.Errorf("decode = %v, name %q; want unknown fields ignored", err, event.Name)
+	}
+	if err := (DecoderOptions{DisallowUnknownFields: true}).decode(data, &event); err == nil {
+		t.Error("expected an error for the unknown field")
+	}
+}
+
+func TestDecoderOptionsTimeFormats(t *testing.T) {
+	opts := DecoderOptions{TimeFormats: []string{"2006-01-02 15:04:05", "2006-01-02"}}
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		data  string
+		check func(event decoderEvent) bool
+	}{
+		{"custom layout", `{"at":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.At.Equal(want) }},
+		{"second layout", `{"at":"2024-03-01"}`, func(e decoderEvent) bool { return e.At.Equal(day) }},
+		{"RFC 3339 kept", `{"at":"2024-03-01T12:30:00Z"}`, func(e decoderEvent) bool { return e.At.Equal(want) }},
+		{"pointer", `{"deadline":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.Deadline != nil && e.Deadline.Equal(want) }},
+		{"slice", `{"history":["2024-03-01","2024-03-01 12:30:00"]}`, func(e decoderEvent) bool {
+			return len(e.History) == 2 && e.History[0].Equal(day) && e.History[1].Equal(want)
+		}},
+		{"embedded struct", `{"createdAt":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.CreatedAt.Equal(want) }},
+		{"embedded pointer", `{"ownedAt":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.DecoderTestOwner != nil && e.OwnedAt.Equal(want) }},
+		{"untagged field", `{"Untagged":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.Untagged.Equal(want) }},
+		{"case-insensitive key", `{"AT":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.At.Equal(want) }},
+		{"duplicate keys", `{"AT":"2024-03-01","at":"2024-03-01 12:30:00"}`, func(e decoderEvent) bool { return e.At.Equal(want) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event decoderEvent
+			if err := opts.decode([]byte(tt.data), &event); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !tt.check(event) {
+				t.Errorf("unexpected result %+v", event)
+			}
+		})
+	}
+
+	var event decoderEvent
+	if err := opts.decode([]byte(`{"at":"yesterday"}`), &event); err == nil {
+		t.Error("expected an error for a value matching no layout")
+	}
+}
+
+func TestDecoderOptionsInvalidTarget(t *testing.T) {
+	var event decoderEvent
+	var nilEvent *decoderEvent
+	targets := map[string]interface{}{"nil": nil, "non-pointer": event, "nil pointer": nilEvent}
+	for _, opts := range []DecoderOptions{{}, {TimeFormats: []string{"2006-01-02"}}} {
+		for name, target := range targets {
+			var invalid *json.InvalidUnmarshalError
+			if err := opts.decode([]byte(`{"at":"2024-03-01"}`), target); !errors.As(err, &invalid) {
+				t.Errorf("decode into %s with %+v = %v, want json.InvalidUnmarshalError", name, opts, err)
+			}
+		}
+	}
+}
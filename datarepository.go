@@ -3,8 +3,11 @@
 package datarepository
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -49,6 +52,14 @@ type DataRepository interface {
 	// Returns ErrInvalidIdentifier if the identifier is invalid.
 	Update(ctx context.Context, identifier EntityIdentifier, value interface{}) error
 
+	// Clone copies an entity to a new identifier. If mutate is not nil, it is applied
+	// to the copied document before it is stored.
+	// Returns ErrNotFound if the source entity does not exist.
+	// Returns ErrAlreadyExists if the target entity already exists.
+	// Returns ErrInvalidInput if mutate returns nil.
+	// Returns ErrInvalidIdentifier if an identifier is invalid.
+	Clone(ctx context.Context, source EntityIdentifier, target EntityIdentifier, mutate func(doc Document) Document) error
+
 	// Delete removes an entity from the repository.
 	// Returns ErrNotFound if the entity does not exist.
 	// Returns ErrInvalidIdentifier if the identifier is invalid.
@@ -93,9 +104,37 @@ type DataRepository interface {
 	// AtomicIncrement increments the value of the given identifier atomically.
 	AtomicIncrement(ctx context.Context, identifier EntityIdentifier) (int64, error)
 
+	// SetString stores a plain string value without a JSON document wrapper.
+	// A ttl of 0 means the value does not expire.
+	// Returns ErrInvalidIdentifier if the identifier is invalid.
+	SetString(ctx context.Context, identifier EntityIdentifier, value string, ttl time.Duration) error
+
+	// GetString returns a plain value stored with SetString or SetBytes.
+	// Returns ErrNotFound if the value does not exist.
+	// Returns ErrInvalidIdentifier if the identifier is invalid.
+	GetString(ctx context.Context, identifier EntityIdentifier) (string, error)
+
+	// SetBytes stores a plain binary value without a JSON document wrapper.
+	// A ttl of 0 means the value does not expire.
+	// Returns ErrInvalidIdentifier if the identifier is invalid.
+	SetBytes(ctx context.Context, identifier EntityIdentifier, value []byte, ttl time.Duration) error
+
+	// GetBytes returns a plain value stored with SetBytes or SetString.
+	// Returns ErrNotFound if the value does not exist.
+	// Returns ErrInvalidIdentifier if the identifier is invalid.
+	GetBytes(ctx context.Context, identifier EntityIdentifier) ([]byte, error)
+
 	// Plugin system
 	RegisterPlugin(plugin RepositoryPlugin) error
 	GetPlugin(name string) (RepositoryPlugin, bool)
+
+	// Relationships between entities
+	RegisterRelationship(relationship Relationship) error
+	GetRelationships() []Relationship
+
+	// CheckIntegrity scans the children of registered relationships for references to
+	// parents that do not exist and optionally repairs them.
+	CheckIntegrity(ctx context.Context, opts IntegrityCheckOptions) (IntegrityReport, error)
 }
 
 // EntityIdentifier represents a unique identifier for an entity
@@ -111,6 +150,21 @@ func (si SimpleIdentifier) String() string {
 	return string(si)
 }
 
+// Document is the generic representation of a stored JSON entity
+type Document map[string]interface{}
+
+// toDocument converts a JSON object into a Document. Numbers are kept as json.Number
+// so that large integers survive a round trip.
+func toDocument(data []byte) (Document, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return nil, fmt.Errorf("%w: entity is not a JSON object", ErrInvalidInput)
+	}
+	return doc, nil
+}
+
 func emptyLogger(logLevel string, logContent string) {}
 
 type LogAdapter func(logLevel string, logContent string)
@@ -160,6 +214,79 @@ func IsOperationFailedError(err error) bool {
 	return errors.Is(err, ErrOperationFailed)
 }
 
+// IsDanglingReferenceError checks if the given error is an ErrDanglingReference error
+func IsDanglingReferenceError(err error) bool {
+	return errors.Is(err, ErrDanglingReference)
+}
+
+// BatchItemError is the failure of a single item in a batch operation
+type BatchItemError struct {
+	Identifier EntityIdentifier
+	Err        error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Identifier, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError aggregates the per-item failures of a batch operation.
+// errors.Is and errors.As match against every contained item error.
+type BatchError struct {
+	Operation string
+	Failures  []*BatchItemError
+}
+
+func (e *BatchError) Error() string {
+	switch len(e.Failures) {
+	case 0:
+		return fmt.Sprintf("%s: no items failed", e.Operation)
+	case 1:
+		return fmt.Sprintf("%s: 1 item failed: %v", e.Operation, e.Failures[0])
+	default:
+		return fmt.Sprintf("%s: %d items failed, first: %v", e.Operation, len(e.Failures), e.Failures[0])
+	}
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// FailedIdentifiers returns the identifiers of all failed items, e.g. for a retry
+func (e *BatchError) FailedIdentifiers() []EntityIdentifier {
+	identifiers := make([]EntityIdentifier, len(e.Failures))
+	for i, failure := range e.Failures {
+		identifiers[i] = failure.Identifier
+	}
+	return identifiers
+}
+
+func (e *BatchError) add(identifier EntityIdentifier, err error) {
+	e.Failures = append(e.Failures, &BatchItemError{Identifier: identifier, Err: err})
+}
+
+// errOrNil returns e as an error if any item failed, nil otherwise
+func (e *BatchError) errOrNil() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// AsBatchError returns the BatchError contained in err, if any
+func AsBatchError(err error) (*BatchError, bool) {
+	var batchErr *BatchError
+	ok := errors.As(err, &batchErr)
+	return batchErr, ok
+}
+
 // RepositoryPlugin defines the interface for database-specific plugins
 type RepositoryPlugin interface {
 	Name() string
@@ -168,7 +295,8 @@ type RepositoryPlugin interface {
 
 // BaseRepository provides a basic implementation of the DataRepository interface
 type BaseRepository struct {
-	plugins map[string]RepositoryPlugin
+	plugins       map[string]RepositoryPlugin
+	relationships []Relationship
 }
 
 // RegisterPlugin adds a new plugin to the repository
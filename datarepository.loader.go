@@ -0,0 +1,183 @@
+// datarepository.loader.go
+
+package datarepository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLoaderWait     = 2 * time.Millisecond
+	DefaultLoaderMaxBatch = 100
+)
+
+// batchReader is implemented by repositories that can fetch many entities in one round trip.
+// readBatch returns the raw stored entities, decodeBatchItem decodes one of them like Read would.
+type batchReader interface {
+	readBatch(ctx context.Context, identifiers []EntityIdentifier) ([]interface{}, []error)
+	decodeBatchItem(identifier EntityIdentifier, raw interface{}, value interface{}) error
+}
+
+// LoaderOptions controls how a Loader groups Reads into batches
+type LoaderOptions struct {
+	// Wait is how long Loads are collected before a batch is dispatched. Defaults to DefaultLoaderWait.
+	Wait time.Duration
+	// MaxBatch dispatches a batch as soon as it holds this many identifiers. Defaults to DefaultLoaderMaxBatch.
+	MaxBatch int
+}
+
+// Loader batches and deduplicates the Reads issued during a single request. Loads issued
+// within the wait window are fetched together, and every identifier is fetched at most once
+// for the lifetime of the Loader; only failures other than ErrNotFound are fetched again.
+// Create one Loader per request; it does not observe writes.
+type Loader struct {
+	ctx     context.Context
+	reader  batchReader
+	opts    LoaderOptions
+	mu      sync.Mutex
+	cache   map[string]*loaderResult
+	pending []*loaderResult
+	timer   *time.Timer
+}
+
+type loaderResult struct {
+	identifier EntityIdentifier
+	done       chan struct{}
+	raw        interface{}
+	err        error
+}
+
+type loaderContextKey struct{}
+
+// NewLoader creates a request-scoped Loader for repository. ctx is used for the batched
+// reads and should be the request context.
+// Returns ErrNotSupported if the repository does not support batched reads.
+func NewLoader(ctx context.Context, repository DataRepository, opts LoaderOptions) (*Loader, error) {
+	reader, ok := repository.(batchReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: repository does not support batched reads", ErrNotSupported)
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultLoaderWait
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = DefaultLoaderMaxBatch
+	}
+	return &Loader{
+		ctx:    ctx,
+		reader: reader,
+		opts:   opts,
+		cache:  make(map[string]*loaderResult),
+	}, nil
+}
+
+// WithLoader returns a copy of ctx carrying loader
+func WithLoader(ctx context.Context, loader *Loader) context.Context {
+	return context.WithValue(ctx, loaderContextKey{}, loader)
+}
+
+// LoaderFromContext returns the Loader stored in ctx by WithLoader
+func LoaderFromContext(ctx context.Context) (*Loader, bool) {
+	loader, ok := ctx.Value(loaderContextKey{}).(*Loader)
+	return loader, ok
+}
+
+// Load reads the entity for identifier into value, like DataRepository.Read.
+// Returns ErrNotFound if the entity does not exist.
+// Returns ErrInvalidIdentifier if the identifier is invalid.
+func (l *Loader) Load(ctx context.Context, identifier EntityIdentifier, value interface{}) error {
+	key := identifier.String()
+
+	var batch []*loaderResult
+	l.mu.Lock()
+	result, ok := l.cache[key]
+	if !ok {
+		result = &loaderResult{identifier: identifier, done: make(chan struct{})}
+		l.cache[key] = result
+		l.pending = append(l.pending, result)
+		if len(l.pending) >= l.opts.MaxBatch {
+			batch = l.takePending()
+		} else if l.timer == nil {
+			l.timer = time.AfterFunc(l.opts.Wait, l.flush)
+		}
+	}
+	l.mu.Unlock()
+
+	if batch != nil {
+		l.dispatch(batch)
+	}
+
+	select {
+	case <-result.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if result.err != nil {
+		return result.err
+	}
+	return l.reader.decodeBatchItem(identifier, result.raw, value)
+}
+
+// Clear removes identifier from the loader's cache, e.g. after it has been written
+func (l *Loader) Clear(identifier EntityIdentifier) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, identifier.String())
+}
+
+// ClearAll empties the loader's cache
+func (l *Loader) ClearAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache = make(map[string]*loaderResult)
+}
+
+func (l *Loader) flush() {
+	l.mu.Lock()
+	batch := l.takePending()
+	l.mu.Unlock()
+
+	if len(batch) > 0 {
+		l.dispatch(batch)
+	}
+}
+
+// takePending must be called with l.mu held
+func (l *Loader) takePending() []*loaderResult {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	batch := l.pending
+	l.pending = nil
+	return batch
+}
+
+func (l *Loader) dispatch(batch []*loaderResult) {
+	identifiers := make([]EntityIdentifier, len(batch))
+	for i, result := range batch {
+		identifiers[i] = result.identifier
+	}
+
+	raws, errs := l.reader.readBatch(l.ctx, identifiers)
+	for i, result := range batch {
+		result.raw, result.err = raws[i], errs[i]
+		if result.err != nil && !IsNotFoundError(result.err) {
+			l.evict(result) // Let later Loads retry transient failures
+		}
+		close(result.done)
+	}
+}
+
+// evict removes result from the cache, unless it was already replaced by a newer Load
+func (l *Loader) evict(result *loaderResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := result.identifier.String()
+	if l.cache[key] == result {
+		delete(l.cache, key)
+	}
+}
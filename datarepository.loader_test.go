@@ -0,0 +1,165 @@
+// datarepository.loader_test.go
+
+package datarepository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingRepository records the batches read through it and can fail them on demand
+type countingRepository struct {
+	*MemoryRepository
+	mu      sync.Mutex
+	batches [][]string
+	fail    error
+}
+
+func (r *countingRepository) readBatch(ctx context.Context, identifiers []EntityIdentifier) ([]interface{}, []error) {
+	r.mu.Lock()
+	keys := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		keys[i] = identifier.String()
+	}
+	r.batches = append(r.batches, keys)
+	fail := r.fail
+	r.fail = nil
+	r.mu.Unlock()
+
+	if fail != nil {
+		errs := make([]error, len(identifiers))
+		for i := range errs {
+			errs[i] = fail
+		}
+		return make([]interface{}, len(identifiers)), errs
+	}
+	return r.MemoryRepository.readBatch(ctx, identifiers)
+}
+
+func (r *countingRepository) batchCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.batches)
+}
+
+func newCountingRepository(t *testing.T, entities map[string]interface{}) *countingRepository {
+	t.Helper()
+	repo, err := NewMemoryRepository(MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryRepository: %v", err)
+	}
+	for key, value := range entities {
+		if err := repo.Create(context.Background(), MemoryIdentifier(key), value); err != nil {
+			t.Fatalf("Create %s: %v", key, err)
+		}
+	}
+	return &countingRepository{MemoryRepository: repo.(*MemoryRepository)}
+}
+
+func TestLoaderBatchesAndDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	repo := newCountingRepository(t, map[string]interface{}{"a": "alpha", "b": "beta"})
+	loader, err := NewLoader(ctx, repo, LoaderOptions{Wait: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	keys := []string{"a", "b", "a", "missing"}
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			errs[i] = loader.Load(ctx, MemoryIdentifier(key), &values[i])
+		}(i, key)
+	}
+	wg.Wait()
+
+	if n := repo.batchCount(); n != 1 {
+		t.Fatalf("expected 1 batch, got %d", n)
+	}
+	if n := len(repo.batches[0]); n != 3 {
+		t.Errorf("expected 3 distinct identifiers in the batch, got %v", repo.batches[0])
+	}
+	for i, want := range []interface{}{"alpha", "beta", "alpha"} {
+		if errs[i] != nil || values[i] != want {
+			t.Errorf("Load %s = %v, %v; want %v", keys[i], values[i], errs[i], want)
+		}
+	}
+	if !IsNotFoundError(errs[3]) {
+		t.Errorf("Load missing: expected ErrNotFound, got %v", errs[3])
+	}
+
+	// Results, including ErrNotFound, are cached for the lifetime of the loader
+	var value interface{}
+	if err := loader.Load(ctx, MemoryIdentifier("a"), &value); err != nil || value != "alpha" {
+		t.Errorf("cached Load a = %v, %v", value, err)
+	}
+	if err := loader.Load(ctx, MemoryIdentifier("missing"), &value); !IsNotFoundError(err) {
+		t.Errorf("cached Load missing: expected ErrNotFound, got %v", err)
+	}
+	if n := repo.batchCount(); n != 1 {
+		t.Errorf("expected cached Loads not to read, got %d batches", n)
+	}
+}
+
+func TestLoaderDispatchesFullBatchWithoutWaiting(t *testing.T) {
+	ctx := context.Background()
+	repo := newCountingRepository(t, map[string]interface{}{"a": "alpha", "b": "beta"})
+	loader, err := NewLoader(ctx, repo, LoaderOptions{Wait: time.Hour, MaxBatch: 2})
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	loadCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			var value interface{}
+			if err := loader.Load(loadCtx, MemoryIdentifier(key), &value); err != nil {
+				t.Errorf("Load %s: %v", key, err)
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if n := repo.batchCount(); n != 1 {
+		t.Errorf("expected 1 batch, got %d", n)
+	}
+}
+
+func TestLoaderRetriesFailedReads(t *testing.T) {
+	ctx := context.Background()
+	repo := newCountingRepository(t, map[string]interface{}{"a": "alpha"})
+	repo.fail = ErrOperationFailed
+	loader, err := NewLoader(ctx, repo, LoaderOptions{Wait: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+
+	var value interface{}
+	if err := loader.Load(ctx, MemoryIdentifier("a"), &value); !errors.Is(err, ErrOperationFailed) {
+		t.Fatalf("expected ErrOperationFailed, got %v", err)
+	}
+	if err := loader.Load(ctx, MemoryIdentifier("a"), &value); err != nil || value != "alpha" {
+		t.Errorf("Load after failure = %v, %v; want alpha", value, err)
+	}
+	if n := repo.batchCount(); n != 2 {
+		t.Errorf("expected the failed read to be retried, got %d batches", n)
+	}
+}
+
+func TestNewLoaderRequiresBatchReads(t *testing.T) {
+	_, err := NewLoader(context.Background(), struct{ DataRepository }{}, LoaderOptions{})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
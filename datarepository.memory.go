@@ -3,7 +3,9 @@
 package datarepository
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
@@ -71,6 +73,9 @@ func (r *MemoryRepository) Create(ctx context.Context, identifier EntityIdentifi
 	if _, exists := r.data[key]; exists {
 		return ErrAlreadyExists
 	}
+	if err := r.checkReferences(identifier, value); err != nil {
+		return err
+	}
 	r.data[key] = value
 	return nil
 }
@@ -95,6 +100,38 @@ func (r *MemoryRepository) Read(ctx context.Context, identifier EntityIdentifier
 	return ErrNotFound
 }
 
+func (r *MemoryRepository) readBatch(ctx context.Context, identifiers []EntityIdentifier) ([]interface{}, []error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	raws := make([]interface{}, len(identifiers))
+	errs := make([]error, len(identifiers))
+	now := time.Now()
+	for i, identifier := range identifiers {
+		key := identifier.String()
+		if expiry, exists := r.expiries[key]; exists && now.After(expiry) {
+			errs[i] = ErrNotFound
+			continue
+		}
+		data, exists := r.data[key]
+		if !exists {
+			errs[i] = ErrNotFound
+			continue
+		}
+		raws[i] = data
+	}
+	return raws, errs
+}
+
+func (r *MemoryRepository) decodeBatchItem(identifier EntityIdentifier, raw interface{}, value interface{}) error {
+	target, ok := value.(*interface{})
+	if !ok {
+		return fmt.Errorf("%w: value must be a *interface{}", ErrInvalidInput)
+	}
+	*target = raw
+	return nil
+}
+
 func (r *MemoryRepository) Update(ctx context.Context, identifier EntityIdentifier, value interface{}) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -103,6 +140,9 @@ func (r *MemoryRepository) Update(ctx context.Context, identifier EntityIdentifi
 	if _, exists := r.data[key]; !exists {
 		return ErrNotFound
 	}
+	if err := r.checkReferences(identifier, value); err != nil {
+		return err
+	}
 	r.data[key] = value
 	return nil
 }
@@ -112,10 +152,83 @@ func (r *MemoryRepository) Upsert(ctx context.Context, identifier EntityIdentifi
 	defer r.mu.Unlock()
 
 	key := identifier.String()
+	if err := r.checkReferences(identifier, value); err != nil {
+		return err
+	}
 	r.data[key] = value
 	return nil
 }
 
+func (r *MemoryRepository) Clone(ctx context.Context, source EntityIdentifier, target EntityIdentifier, mutate func(doc Document) Document) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sourceKey := source.String()
+	targetKey := target.String()
+	if expiry, exists := r.expiries[sourceKey]; exists && time.Now().After(expiry) {
+		return ErrNotFound
+	}
+	value, exists := r.data[sourceKey]
+	if !exists {
+		return ErrNotFound
+	}
+	if _, exists := r.data[targetKey]; exists {
+		return ErrAlreadyExists
+	}
+
+	if mutate == nil {
+		copied, err := deepCopyValue(value)
+		if err != nil {
+			return err
+		}
+		if err := r.checkReferences(target, copied); err != nil {
+			return err
+		}
+		r.data[targetKey] = copied
+		return nil
+	}
+
+	// Round-trip through JSON so the clone does not share state with the source
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	doc, err := toDocument(data)
+	if err != nil {
+		return err
+	}
+	if doc = mutate(doc); doc == nil {
+		return fmt.Errorf("%w: mutate returned no document", ErrInvalidInput)
+	}
+	if err := r.checkReferences(target, doc); err != nil {
+		return err
+	}
+	r.data[targetKey] = doc
+	return nil
+}
+
+// deepCopyValue returns a copy of a stored value that shares no state with it. Plain values
+// are copied directly, anything else is round-tripped through JSON.
+func deepCopyValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return append([]byte(nil), v...), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var copied interface{}
+	if err := decoder.Decode(&copied); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return copied, nil
+}
+
 func (r *MemoryRepository) Delete(ctx context.Context, identifier EntityIdentifier) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -312,6 +425,148 @@ func (r *MemoryRepository) AtomicIncrement(ctx context.Context, identifier Entit
 	}
 }
 
+func (r *MemoryRepository) CheckIntegrity(ctx context.Context, opts IntegrityCheckOptions) (IntegrityReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := IntegrityReport{}
+	for _, relationship := range r.relationshipsFor(opts.ChildPrefixes) {
+		for key, value := range r.data {
+			if !strings.HasPrefix(key, relationship.ChildPrefix+DefaultKeySeparator) || r.expired(key) {
+				continue
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			if _, err := toDocument(data); err != nil {
+				continue // Skip values that are not JSON documents
+			}
+			report.Checked++
+
+			parentID, ok := referenceID(data, relationship)
+			if !ok || r.parentExists(relationship, parentID) {
+				continue
+			}
+			report.Dangling = append(report.Dangling, DanglingReference{Child: MemoryIdentifier(key), Relationship: relationship, ParentID: parentID})
+
+			switch opts.Repair {
+			case IntegrityRepairDeleteChild:
+				delete(r.data, key)
+				delete(r.expiries, key)
+				report.Repaired++
+			case IntegrityRepairClearReference:
+				doc, err := toDocument(data)
+				if err != nil {
+					continue
+				}
+				delete(doc, relationship.Field)
+				r.data[key] = doc
+				report.Repaired++
+			}
+		}
+	}
+	return report, nil
+}
+
+// parentExists must be called with r.mu held
+func (r *MemoryRepository) parentExists(relationship Relationship, parentID string) bool {
+	key := RedisIdentifier{EntityPrefix: relationship.ParentPrefix, ID: parentID}.String()
+	_, exists := r.data[key]
+	return exists && !r.expired(key)
+}
+
+// expired reports whether key has an expiration in the past; must be called with r.mu held
+func (r *MemoryRepository) expired(key string) bool {
+	expiry, exists := r.expiries[key]
+	return exists && time.Now().After(expiry)
+}
+
+// checkReferences must be called with r.mu held
+func (r *MemoryRepository) checkReferences(identifier EntityIdentifier, value interface{}) error {
+	childPrefix, ok := entityPrefixOf(identifier)
+	if !ok {
+		return nil
+	}
+	relationships := r.enforcedRelationships(childPrefix)
+	if len(relationships) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil // Values that are not JSON documents cannot hold references
+	}
+	for _, relationship := range relationships {
+		parentID, ok := referenceID(data, relationship)
+		if ok && !r.parentExists(relationship, parentID) {
+			return fmt.Errorf("%w: %s %q", ErrDanglingReference, relationship.ParentPrefix, parentID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) SetString(ctx context.Context, identifier EntityIdentifier, value string, ttl time.Duration) error {
+	return r.setPlain(identifier, value, ttl)
+}
+
+func (r *MemoryRepository) GetString(ctx context.Context, identifier EntityIdentifier) (string, error) {
+	value, err := r.getPlain(identifier)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (r *MemoryRepository) SetBytes(ctx context.Context, identifier EntityIdentifier, value []byte, ttl time.Duration) error {
+	// Copy the value so later changes by the caller do not affect the stored one
+	return r.setPlain(identifier, append([]byte(nil), value...), ttl)
+}
+
+func (r *MemoryRepository) GetBytes(ctx context.Context, identifier EntityIdentifier) ([]byte, error) {
+	return r.getPlain(identifier)
+}
+
+func (r *MemoryRepository) setPlain(identifier EntityIdentifier, value interface{}, ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("%w: ttl must not be negative", ErrInvalidInput)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := identifier.String()
+	r.data[key] = value
+	if ttl == 0 {
+		delete(r.expiries, key)
+		return nil
+	}
+	if r.expiries == nil {
+		r.expiries = make(map[string]time.Time)
+	}
+	r.expiries[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (r *MemoryRepository) getPlain(identifier EntityIdentifier) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := identifier.String()
+	if expiry, exists := r.expiries[key]; exists && time.Now().After(expiry) {
+		return nil, ErrNotFound
+	}
+	switch value := r.data[key].(type) {
+	case nil:
+		return nil, ErrNotFound
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return append([]byte(nil), value...), nil
+	default:
+		return nil, fmt.Errorf("%w: %s does not hold a plain value", ErrInvalidInput, key)
+	}
+}
+
 // Add a method to clean up expired keys
 func (r *MemoryRepository) cleanupExpired() {
 	r.mu.Lock()
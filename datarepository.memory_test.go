@@ -0,0 +1,324 @@
+// datarepository.memory_test.go
+
+package datarepository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestMemoryRepository(t *testing.T) *MemoryRepository {
+	t.Helper()
+	repo, err := NewMemoryRepository(MemoryConfig{})
+	if err != nil {
+		t.Fatalf("NewMemoryRepository: %v", err)
+	}
+	return repo.(*MemoryRepository)
+}
+
+func readMemory(t *testing.T, repo *MemoryRepository, identifier EntityIdentifier) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := repo.Read(context.Background(), identifier, &value); err != nil {
+		t.Fatalf("Read %s: %v", identifier, err)
+	}
+	return value
+}
+
+func TestMemoryCloneDeepCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	source := RedisIdentifier{EntityPrefix: "project", ID: "1"}
+	target := RedisIdentifier{EntityPrefix: "project", ID: "2"}
+	tags := []interface{}{"a"}
+	if err := repo.Create(ctx, source, map[string]interface{}{"name": "template", "tags": tags, "size": 9007199254740993}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := repo.Clone(ctx, source, target, nil); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	tags[0] = "changed"
+	readMemory(t, repo, source).(map[string]interface{})["name"] = "changed"
+
+	want := map[string]interface{}{"name": "template", "tags": []interface{}{"a"}, "size": json.Number("9007199254740993")}
+	got := readMemory(t, repo, target)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("clone = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryCloneCopiesPlainValues(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	source := RedisIdentifier{EntityPrefix: "blob", ID: "1"}
+	target := RedisIdentifier{EntityPrefix: "blob", ID: "2"}
+	if err := repo.SetBytes(ctx, source, []byte("abc"), 0); err != nil {
+		t.Fatalf("SetBytes: %v", err)
+	}
+	if err := repo.Clone(ctx, source, target, nil); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	repo.data[source.String()].([]byte)[0] = 'x'
+
+	got, err := repo.GetBytes(ctx, target)
+	if err != nil || string(got) != "abc" {
+		t.Errorf("GetBytes clone = %q, %v; want abc", got, err)
+	}
+}
+
+func TestMemoryCloneMutates(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	source := RedisIdentifier{EntityPrefix: "project", ID: "1"}
+	target := RedisIdentifier{EntityPrefix: "project", ID: "2"}
+	if err := repo.Create(ctx, source, map[string]interface{}{"name": "template"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	err := repo.Clone(ctx, source, target, func(doc Document) Document {
+		doc["name"] = "copy"
+		return doc
+	})
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if got := readMemory(t, repo, target).(Document)["name"]; got != "copy" {
+		t.Errorf("clone name = %v, want copy", got)
+	}
+	if got := readMemory(t, repo, source).(map[string]interface{})["name"]; got != "template" {
+		t.Errorf("source name = %v, want template", got)
+	}
+}
+
+func TestMemoryCloneErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	source := RedisIdentifier{EntityPrefix: "project", ID: "1"}
+	existing := RedisIdentifier{EntityPrefix: "project", ID: "2"}
+	target := RedisIdentifier{EntityPrefix: "project", ID: "3"}
+	for _, identifier := range []EntityIdentifier{source, existing} {
+		if err := repo.Create(ctx, identifier, map[string]interface{}{"name": identifier.String()}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		source EntityIdentifier
+		target EntityIdentifier
+		mutate func(doc Document) Document
+		want   error
+	}{
+		{"missing source", RedisIdentifier{EntityPrefix: "project", ID: "missing"}, target, nil, ErrNotFound},
+		{"existing target", source, existing, nil, ErrAlreadyExists},
+		{"nil document", source, target, func(doc Document) Document { return nil }, ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.Clone(ctx, tt.source, tt.target, tt.mutate); !errors.Is(err, tt.want) {
+				t.Errorf("Clone = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	var value interface{}
+	if err := repo.Read(ctx, target, &value); !IsNotFoundError(err) {
+		t.Errorf("failed clones must not create the target, Read = %v", err)
+	}
+	if got := readMemory(t, repo, existing).(map[string]interface{})["name"]; got != existing.String() {
+		t.Errorf("existing target was overwritten: %v", got)
+	}
+}
+
+func TestMemoryCheckReferencesEnforced(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	if err := repo.RegisterRelationship(Relationship{ChildPrefix: "task", ParentPrefix: "project", Field: "projectId", Enforce: true}); err != nil {
+		t.Fatalf("RegisterRelationship: %v", err)
+	}
+	task := RedisIdentifier{EntityPrefix: "task", ID: "1"}
+	project := RedisIdentifier{EntityPrefix: "project", ID: "1"}
+
+	if err := repo.Create(ctx, task, map[string]interface{}{"projectId": "1"}); !errors.Is(err, ErrDanglingReference) {
+		t.Fatalf("Create with missing parent = %v, want ErrDanglingReference", err)
+	}
+	if err := repo.Create(ctx, task, map[string]interface{}{"name": "unassigned"}); err != nil {
+		t.Fatalf("Create without reference: %v", err)
+	}
+	if err := repo.Create(ctx, project, map[string]interface{}{"name": "project"}); err != nil {
+		t.Fatalf("Create parent: %v", err)
+	}
+	if err := repo.Update(ctx, task, map[string]interface{}{"projectId": "1"}); err != nil {
+		t.Fatalf("Update with existing parent: %v", err)
+	}
+	if err := repo.Upsert(ctx, task, map[string]interface{}{"projectId": "2"}); !errors.Is(err, ErrDanglingReference) {
+		t.Errorf("Upsert with missing parent = %v, want ErrDanglingReference", err)
+	}
+	err := repo.Clone(ctx, task, RedisIdentifier{EntityPrefix: "task", ID: "2"}, func(doc Document) Document {
+		doc["projectId"] = "2"
+		return doc
+	})
+	if !errors.Is(err, ErrDanglingReference) {
+		t.Errorf("Clone with missing parent = %v, want ErrDanglingReference", err)
+	}
+
+	repo.expiries = map[string]time.Time{project.String(): time.Now().Add(-time.Second)}
+	if err := repo.Update(ctx, task, map[string]interface{}{"projectId": "1"}); !errors.Is(err, ErrDanglingReference) {
+		t.Errorf("Update with expired parent = %v, want ErrDanglingReference", err)
+	}
+}
+
+func TestMemoryCheckIntegrity(t *testing.T) {
+	relationship := Relationship{ChildPrefix: "task", ParentPrefix: "project", Field: "projectId"}
+	setup := func(t *testing.T) *MemoryRepository {
+		repo := newTestMemoryRepository(t)
+		if err := repo.RegisterRelationship(relationship); err != nil {
+			t.Fatalf("RegisterRelationship: %v", err)
+		}
+		repo.data = map[string]interface{}{
+			"project:1": map[string]interface{}{"name": "live"},
+			"project:2": map[string]interface{}{"name": "expired"},
+			"task:1":    map[string]interface{}{"projectId": "1"},
+			"task:2":    map[string]interface{}{"projectId": "missing", "name": "orphan"},
+			"task:3":    map[string]interface{}{"projectId": "2"},
+			"task:4":    "plain value",
+			"task:5":    map[string]interface{}{"name": "unassigned"},
+		}
+		repo.expiries = map[string]time.Time{"project:2": time.Now().Add(-time.Second)}
+		return repo
+	}
+
+	tests := []struct {
+		name     string
+		repair   IntegrityRepair
+		repaired int
+		check    func(t *testing.T, repo *MemoryRepository)
+	}{
+		{"report only", IntegrityRepairNone, 0, func(t *testing.T, repo *MemoryRepository) {
+			if len(repo.data) != 7 {
+				t.Errorf("expected no changes, got %v", repo.data)
+			}
+		}},
+		{"delete child", IntegrityRepairDeleteChild, 2, func(t *testing.T, repo *MemoryRepository) {
+			for _, key := range []string{"task:2", "task:3"} {
+				if _, exists := repo.data[key]; exists {
+					t.Errorf("expected %s to be deleted", key)
+				}
+			}
+			if _, exists := repo.data["task:1"]; !exists {
+				t.Error("expected task:1 to be kept")
+			}
+		}},
+		{"clear reference", IntegrityRepairClearReference, 2, func(t *testing.T, repo *MemoryRepository) {
+			want := Document{"name": "orphan"}
+			if got := repo.data["task:2"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("task:2 = %v, want %v", got, want)
+			}
+			if _, exists := repo.data["task:3"].(Document)["projectId"]; exists {
+				t.Error("expected the reference of task:3 to be cleared")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setup(t)
+			report, err := repo.CheckIntegrity(context.Background(), IntegrityCheckOptions{Repair: tt.repair})
+			if err != nil {
+				t.Fatalf("CheckIntegrity: %v", err)
+			}
+			if report.Checked != 4 {
+				t.Errorf("Checked = %d, want 4", report.Checked)
+			}
+			dangling := map[string]string{}
+			for _, reference := range report.Dangling {
+				dangling[reference.Child.String()] = reference.ParentID
+			}
+			if want := map[string]string{"task:2": "missing", "task:3": "2"}; !reflect.DeepEqual(dangling, want) {
+				t.Errorf("Dangling = %v, want %v", dangling, want)
+			}
+			if report.Repaired != tt.repaired {
+				t.Errorf("Repaired = %d, want %d", report.Repaired, tt.repaired)
+			}
+			tt.check(t, repo)
+		})
+	}
+}
+
+func TestMemoryPlainValues(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	token := RedisIdentifier{EntityPrefix: "token", ID: "1"}
+	blob := RedisIdentifier{EntityPrefix: "blob", ID: "1"}
+
+	if err := repo.SetString(ctx, token, "secret", 0); err != nil {
+		t.Fatalf("SetString: %v", err)
+	}
+	if got, err := repo.GetString(ctx, token); err != nil || got != "secret" {
+		t.Errorf("GetString = %q, %v; want secret", got, err)
+	}
+	if got, err := repo.GetBytes(ctx, token); err != nil || string(got) != "secret" {
+		t.Errorf("GetBytes of a string = %q, %v; want secret", got, err)
+	}
+
+	value := []byte("abc")
+	if err := repo.SetBytes(ctx, blob, value, 0); err != nil {
+		t.Fatalf("SetBytes: %v", err)
+	}
+	value[0] = 'x'
+	got, err := repo.GetBytes(ctx, blob)
+	if err != nil || string(got) != "abc" {
+		t.Fatalf("GetBytes after changing the input = %q, %v; want abc", got, err)
+	}
+	got[0] = 'y'
+	if got, _ := repo.GetBytes(ctx, blob); string(got) != "abc" {
+		t.Errorf("GetBytes after changing a result = %q, want abc", got)
+	}
+
+	if err := repo.SetString(ctx, token, "secret", -time.Second); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("SetString with negative TTL = %v, want ErrInvalidInput", err)
+	}
+	if _, err := repo.GetString(ctx, RedisIdentifier{EntityPrefix: "token", ID: "missing"}); !IsNotFoundError(err) {
+		t.Errorf("GetString of a missing key = %v, want ErrNotFound", err)
+	}
+	document := RedisIdentifier{EntityPrefix: "project", ID: "1"}
+	if err := repo.Create(ctx, document, map[string]interface{}{"name": "project"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := repo.GetString(ctx, document); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("GetString of a document = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestMemoryPlainValuesExpire(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestMemoryRepository(t)
+	expiring := RedisIdentifier{EntityPrefix: "token", ID: "1"}
+	persisted := RedisIdentifier{EntityPrefix: "token", ID: "2"}
+
+	if err := repo.SetString(ctx, expiring, "short", 20*time.Millisecond); err != nil {
+		t.Fatalf("SetString: %v", err)
+	}
+	if err := repo.SetBytes(ctx, persisted, []byte("short"), 20*time.Millisecond); err != nil {
+		t.Fatalf("SetBytes: %v", err)
+	}
+	// Setting a value without TTL removes the previous expiration
+	if err := repo.SetBytes(ctx, persisted, []byte("long"), 0); err != nil {
+		t.Fatalf("SetBytes: %v", err)
+	}
+	if got, err := repo.GetString(ctx, expiring); err != nil || got != "short" {
+		t.Fatalf("GetString before expiry = %q, %v; want short", got, err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, err := repo.GetString(ctx, expiring); !IsNotFoundError(err) {
+		t.Errorf("GetString after expiry = %v, want ErrNotFound", err)
+	}
+	if got, err := repo.GetBytes(ctx, persisted); err != nil || string(got) != "long" {
+		t.Errorf("GetBytes without TTL = %q, %v; want long", got, err)
+	}
+}
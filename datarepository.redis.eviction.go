@@ -0,0 +1,175 @@
+// datarepository.redis.eviction.go
+
+package datarepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EvictionMode declares whether the entities under an entity prefix may be evicted by Redis
+type EvictionMode string
+
+const (
+	// EvictionModeEvictable marks cache-like entities that can be recomputed when evicted
+	EvictionModeEvictable EvictionMode = "evictable"
+	// EvictionModeAuthoritative marks source-of-truth entities that must never be evicted
+	EvictionModeAuthoritative EvictionMode = "authoritative"
+)
+
+// EvictionConflictAction decides what happens when a write conflicts with the server's eviction policy
+type EvictionConflictAction string
+
+const (
+	// EvictionConflictWarn logs conflicts when the policy is detected and lets writes through
+	EvictionConflictWarn EvictionConflictAction = "warn"
+	// EvictionConflictRefuse rejects conflicting writes with ErrEvictionPolicyConflict
+	EvictionConflictRefuse EvictionConflictAction = "refuse"
+)
+
+const (
+	evictionDetectionTimeout = 5 * time.Second
+	evictionPolicyNone       = "noeviction"
+	evictionPolicyAllKeys    = "allkeys-"
+	evictionPolicyVolatile   = "volatile-"
+)
+
+var (
+	ErrEvictionPolicyConflict = errors.New("write conflicts with the server eviction policy")
+)
+
+// RedisEvictionPolicy is the memory limit and eviction policy reported by the server
+type RedisEvictionPolicy struct {
+	// MaxMemory is the configured memory limit in bytes. 0 means no limit.
+	MaxMemory int64
+	// Policy is the maxmemory-policy, e.g. "noeviction" or "allkeys-lru"
+	Policy string
+}
+
+// CanEvict reports whether the server may evict the given kind of key
+func (p RedisEvictionPolicy) CanEvict(withTTL bool) bool {
+	if p.MaxMemory == 0 {
+		return false
+	}
+	if strings.HasPrefix(p.Policy, evictionPolicyAllKeys) {
+		return true
+	}
+	return withTTL && strings.HasPrefix(p.Policy, evictionPolicyVolatile)
+}
+
+// EvictionPolicy returns the last detected eviction policy. The second return value is
+// false if the policy has not been detected (yet).
+func (r *RedisRepository) EvictionPolicy() (RedisEvictionPolicy, bool) {
+	r.evictionMu.RLock()
+	defer r.evictionMu.RUnlock()
+	if r.evictionPolicy == nil {
+		return RedisEvictionPolicy{}, false
+	}
+	return *r.evictionPolicy, true
+}
+
+// RefreshEvictionPolicy reads maxmemory and maxmemory-policy from the server and logs every
+// configured entity prefix whose eviction mode conflicts with them.
+func (r *RedisRepository) RefreshEvictionPolicy(ctx context.Context) (RedisEvictionPolicy, error) {
+	policy, err := r.detectEvictionPolicy(ctx)
+	if err != nil {
+		return policy, fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
+
+	r.evictionMu.Lock()
+	r.evictionPolicy = &policy
+	r.evictionMu.Unlock()
+
+	for entityPrefix, mode := range r.evictionModes {
+		switch {
+		case mode == EvictionModeAuthoritative && policy.CanEvict(false):
+			r.logger("WARN", fmt.Sprintf("go-datarepository: entity prefix %q is authoritative but maxmemory-policy %q may evict it", entityPrefix, policy.Policy))
+		case mode == EvictionModeEvictable && policy.MaxMemory > 0 && policy.Policy == evictionPolicyNone:
+			r.logger("WARN", fmt.Sprintf("go-datarepository: entity prefix %q is evictable but maxmemory-policy is %q; writes will fail once maxmemory is reached", entityPrefix, policy.Policy))
+		case mode == EvictionModeEvictable && strings.HasPrefix(policy.Policy, evictionPolicyVolatile):
+			r.logger("WARN", fmt.Sprintf("go-datarepository: entity prefix %q is evictable but maxmemory-policy %q only evicts keys with an expiration", entityPrefix, policy.Policy))
+		}
+	}
+
+	return policy, nil
+}
+
+func (r *RedisRepository) detectEvictionPolicy(ctx context.Context) (RedisEvictionPolicy, error) {
+	policy := RedisEvictionPolicy{}
+
+	config, err := r.client.ConfigGet(ctx, "maxmemory*").Result()
+	if err == nil {
+		policy.Policy = config["maxmemory-policy"]
+		policy.MaxMemory, err = strconv.ParseInt(config["maxmemory"], 10, 64)
+		if err == nil && policy.Policy != "" {
+			return policy, nil
+		}
+	}
+
+	// CONFIG is often disabled on managed services; INFO exposes the same values
+	info, err := r.client.Info(ctx, "memory").Result()
+	if err != nil {
+		return policy, err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		switch name {
+		case "maxmemory":
+			policy.MaxMemory, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory_policy":
+			policy.Policy = value
+		}
+	}
+	if policy.Policy == "" {
+		return policy, fmt.Errorf("maxmemory policy not reported by server")
+	}
+	return policy, nil
+}
+
+// validateEvictionConfig returns ErrInvalidInput for unknown eviction modes or conflict actions
+func validateEvictionConfig(modes map[string]EvictionMode, action EvictionConflictAction) error {
+	switch action {
+	case EvictionConflictWarn, EvictionConflictRefuse:
+	default:
+		return fmt.Errorf("%w: unknown eviction conflict action %q", ErrInvalidInput, action)
+	}
+	for entityPrefix, mode := range modes {
+		switch mode {
+		case EvictionModeEvictable, EvictionModeAuthoritative:
+		default:
+			return fmt.Errorf("%w: unknown eviction mode %q for entity prefix %q", ErrInvalidInput, mode, entityPrefix)
+		}
+	}
+	return nil
+}
+
+// checkEviction returns ErrEvictionPolicyConflict if writing identifier (with or without a TTL)
+// would expose an authoritative entity to eviction and the repository is set to refuse such writes.
+// Until the policy has been detected, such writes retry detection and are refused if it fails.
+func (r *RedisRepository) checkEviction(ctx context.Context, identifier EntityIdentifier, withTTL bool) error {
+	if r.evictionAction != EvictionConflictRefuse {
+		return nil
+	}
+	entityPrefix, ok := entityPrefixOf(identifier)
+	if !ok || r.evictionModes[entityPrefix] != EvictionModeAuthoritative {
+		return nil
+	}
+	policy, detected := r.EvictionPolicy()
+	if !detected {
+		var err error
+		if policy, err = r.RefreshEvictionPolicy(ctx); err != nil {
+			return fmt.Errorf("%w: entity prefix %q is authoritative but the eviction policy is unknown: %v", ErrEvictionPolicyConflict, entityPrefix, err)
+		}
+	}
+	if !policy.CanEvict(withTTL) {
+		return nil
+	}
+	return fmt.Errorf("%w: entity prefix %q is authoritative but maxmemory-policy is %q", ErrEvictionPolicyConflict, entityPrefix, policy.Policy)
+}
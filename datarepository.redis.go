@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -45,7 +46,25 @@ type RedisConfig struct {
 	ConnectionString string
 	KeyPrefix        string
 	KeySeparator     string
-	logger           LogAdapter
+	// FallbackKeyPrefix enables dual-read mode: keys missing under KeyPrefix are
+	// looked up under FallbackKeyPrefix. Used while migrating to a new prefix.
+	FallbackKeyPrefix string
+	// FallbackKeySeparator is the separator used with FallbackKeyPrefix.
+	// Defaults to KeySeparator.
+	FallbackKeySeparator string
+	// EntityEvictionModes declares per entity prefix whether entities may be evicted.
+	// When set, the server's eviction policy is detected on startup.
+	EntityEvictionModes map[string]EvictionMode
+	// EvictionConflictAction decides how writes that conflict with the eviction
+	// policy are handled. Defaults to EvictionConflictWarn.
+	EvictionConflictAction EvictionConflictAction
+	// DecoderOptions configures how documents are decoded on Read
+	DecoderOptions DecoderOptions
+	// EntityDecoderOptions overrides DecoderOptions per entity prefix
+	EntityDecoderOptions map[string]DecoderOptions
+	// CommandSampler records a fraction of all commands for debugging. Disabled when nil.
+	CommandSampler *CommandSamplerConfig
+	logger         LogAdapter
 }
 
 type redisServerInfo struct {
@@ -102,10 +121,20 @@ func (ri RedisIdentifier) String() string {
 
 type RedisRepository struct {
 	BaseRepository
-	client    redis.UniversalClient
-	prefix    string
-	separator string
-	logger    LogAdapter
+	client            redis.UniversalClient
+	prefix            string
+	separator         string
+	fallbackPrefix    string
+	fallbackSeparator string
+	evictionModes     map[string]EvictionMode
+	evictionAction    EvictionConflictAction
+	evictionPolicy    *RedisEvictionPolicy
+	evictionMu        sync.RWMutex
+	decoderOptions    DecoderOptions
+	entityDecoders    map[string]DecoderOptions
+	indexFields       map[string][]IndexField
+	sampler           *commandSampler
+	logger            LogAdapter
 }
 
 func (r *RedisRepository) initBaseRepository() {
@@ -134,6 +163,15 @@ func NewRedisRepository(config Config) (DataRepository, error) {
 	if redisConfig.KeySeparator == "" {
 		redisConfig.KeySeparator = DefaultKeySeparator
 	}
+	if redisConfig.FallbackKeySeparator == "" {
+		redisConfig.FallbackKeySeparator = redisConfig.KeySeparator
+	}
+	if redisConfig.EvictionConflictAction == "" {
+		redisConfig.EvictionConflictAction = EvictionConflictWarn
+	}
+	if err := validateEvictionConfig(redisConfig.EntityEvictionModes, redisConfig.EvictionConflictAction); err != nil {
+		return nil, err
+	}
 	if redisConfig.logger == nil {
 		redisConfig.logger = emptyLogger
 	}
@@ -143,6 +181,14 @@ func NewRedisRepository(config Config) (DataRepository, error) {
 		return nil, err
 	}
 
+	var sampler *commandSampler
+	if redisConfig.CommandSampler != nil {
+		sampler, err = newCommandSampler(*redisConfig.CommandSampler)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var client redis.UniversalClient
 
 	switch serverInfo.Mode {
@@ -173,12 +219,33 @@ func NewRedisRepository(config Config) (DataRepository, error) {
 		return nil, fmt.Errorf("%w: unsupported Redis mode", ErrInvalidInput)
 	}
 
-	return &RedisRepository{
-		client:    client,
-		prefix:    redisConfig.KeyPrefix,
-		separator: redisConfig.KeySeparator,
-		logger:    redisConfig.logger,
-	}, nil
+	if sampler != nil {
+		client.AddHook(sampler)
+	}
+
+	repo := &RedisRepository{
+		client:            client,
+		prefix:            redisConfig.KeyPrefix,
+		separator:         redisConfig.KeySeparator,
+		fallbackPrefix:    redisConfig.FallbackKeyPrefix,
+		fallbackSeparator: redisConfig.FallbackKeySeparator,
+		evictionModes:     redisConfig.EntityEvictionModes,
+		evictionAction:    redisConfig.EvictionConflictAction,
+		decoderOptions:    redisConfig.DecoderOptions,
+		entityDecoders:    redisConfig.EntityDecoderOptions,
+		sampler:           sampler,
+		logger:            redisConfig.logger,
+	}
+
+	if len(repo.evictionModes) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), evictionDetectionTimeout)
+		defer cancel()
+		if _, err := repo.RefreshEvictionPolicy(ctx); err != nil {
+			repo.logger("WARN", fmt.Sprintf("go-datarepository: could not detect eviction policy: %v", err))
+		}
+	}
+
+	return repo, nil
 }
 
 func (r *RedisRepository) validateKey(key string, allowPattern bool) error {
@@ -284,11 +351,15 @@ func (r *RedisRepository) Create(ctx context.Context, identifier EntityIdentifie
 		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
 
-	exists, err := r.client.Exists(ctx, key).Result()
+	if err := r.checkEviction(ctx, identifier, false); err != nil {
+		return err
+	}
+
+	exists, err := r.existsWithFallback(ctx, key)
 	if err != nil {
 		return err
 	}
-	if exists == 1 {
+	if exists {
 		return ErrAlreadyExists
 	}
 
@@ -296,6 +367,12 @@ func (r *RedisRepository) Create(ctx context.Context, identifier EntityIdentifie
 	if err != nil {
 		return err
 	}
+	if err := r.checkReferences(ctx, identifier, data); err != nil {
+		return err
+	}
+	if data, err = r.normalizeIndexFields(identifier, data); err != nil {
+		return err
+	}
 
 	return r.client.Do(ctx, "JSON.SET", key, ".", string(data)).Err()
 }
@@ -307,6 +384,11 @@ func (r *RedisRepository) Read(ctx context.Context, identifier EntityIdentifier,
 	}
 
 	data, err := r.client.Do(ctx, "JSON.GET", key).Result()
+	if err == redis.Nil {
+		if fallbackKey, ok := r.fallbackKey(key); ok {
+			data, err = r.client.Do(ctx, "JSON.GET", fallbackKey).Result()
+		}
+	}
 	if err != nil {
 		if err == redis.Nil {
 			return ErrNotFound
@@ -314,7 +396,82 @@ func (r *RedisRepository) Read(ctx context.Context, identifier EntityIdentifier,
 		return err
 	}
 
-	return json.Unmarshal([]byte(data.(string)), value)
+	return r.decodeEntity(identifier, data.(string), value)
+}
+
+func (r *RedisRepository) readBatch(ctx context.Context, identifiers []EntityIdentifier) ([]interface{}, []error) {
+	raws := make([]interface{}, len(identifiers))
+	errs := make([]error, len(identifiers))
+	keys := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		key, err := r.identifierToKey(identifier, false)
+		if err != nil {
+			errs[i] = fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
+			continue
+		}
+		keys[i] = key
+	}
+
+	// The first round reads the current keys, a second one the fallback keys of those
+	// that were missing in dual-read mode.
+	for round := 0; round < 2; round++ {
+		pipe := r.client.Pipeline()
+		cmds := make(map[int]*redis.Cmd)
+		for i, key := range keys {
+			if key == "" || raws[i] != nil || (round > 0 && errs[i] != ErrNotFound) {
+				continue
+			}
+			if round > 0 {
+				fallbackKey, ok := r.fallbackKey(key)
+				if !ok {
+					continue
+				}
+				key = fallbackKey
+			}
+			cmds[i] = pipe.Do(ctx, "JSON.GET", key)
+		}
+		if len(cmds) == 0 {
+			break
+		}
+		_, _ = pipe.Exec(ctx) // Errors are reported per command
+
+		for i, cmd := range cmds {
+			data, err := cmd.Text()
+			switch {
+			case err == redis.Nil:
+				errs[i] = ErrNotFound
+			case err != nil:
+				errs[i] = err
+			default:
+				raws[i], errs[i] = data, nil
+			}
+		}
+	}
+
+	return raws, errs
+}
+
+func (r *RedisRepository) decodeBatchItem(identifier EntityIdentifier, raw interface{}, value interface{}) error {
+	return r.decodeEntity(identifier, raw.(string), value)
+}
+
+// decodeEntity decodes a stored document into value
+func (r *RedisRepository) decodeEntity(identifier EntityIdentifier, data string, value interface{}) error {
+	raw, err := r.denormalizeIndexFields(identifier, []byte(data))
+	if err != nil {
+		return err
+	}
+	return r.decoderFor(identifier).decode(raw, value)
+}
+
+// decoderFor returns the decoder options of the identifier's entity prefix
+func (r *RedisRepository) decoderFor(identifier EntityIdentifier) DecoderOptions {
+	if entityPrefix, ok := entityPrefixOf(identifier); ok {
+		if options, ok := r.entityDecoders[entityPrefix]; ok {
+			return options
+		}
+	}
+	return r.decoderOptions
 }
 
 func (r *RedisRepository) Update(ctx context.Context, identifier EntityIdentifier, value interface{}) error {
@@ -323,20 +480,33 @@ func (r *RedisRepository) Update(ctx context.Context, identifier EntityIdentifie
 		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
 
-	exists, err := r.client.Exists(ctx, key).Result()
+	if err := r.checkEviction(ctx, identifier, false); err != nil {
+		return err
+	}
+
+	exists, err := r.existsWithFallback(ctx, key)
 	if err != nil {
 		return err
 	}
-	if exists == 0 {
+	if !exists {
 		return ErrNotFound
 	}
+	if err := r.adoptFallback(ctx, key); err != nil {
+		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
 
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
+	if err := r.checkReferences(ctx, identifier, data); err != nil {
+		return err
+	}
+	if data, err = r.normalizeIndexFields(identifier, data); err != nil {
+		return err
+	}
 
-	return r.client.Do(ctx, "JSON.SET", key, ".", string(data)).Err()
+	return r.setJSON(ctx, key, data)
 }
 
 func (r *RedisRepository) Upsert(ctx context.Context, identifier EntityIdentifier, value interface{}) error {
@@ -345,12 +515,132 @@ func (r *RedisRepository) Upsert(ctx context.Context, identifier EntityIdentifie
 		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
 
+	if err := r.checkEviction(ctx, identifier, false); err != nil {
+		return err
+	}
+
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
+	if err := r.checkReferences(ctx, identifier, data); err != nil {
+		return err
+	}
+	if data, err = r.normalizeIndexFields(identifier, data); err != nil {
+		return err
+	}
+	if err := r.adoptFallback(ctx, key); err != nil {
+		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
+
+	return r.setJSON(ctx, key, data)
+}
+
+// copyPersistScript copies KEYS[1] to KEYS[2] without its TTL. PERSIST only runs if the
+// copy succeeded, so an existing target keeps its expiration.
+var copyPersistScript = redis.NewScript(`
+if redis.call("COPY", KEYS[1], KEYS[2]) == 1 then
+	redis.call("PERSIST", KEYS[2])
+	return 1
+end
+return 0
+`)
+
+func (r *RedisRepository) Clone(ctx context.Context, source EntityIdentifier, target EntityIdentifier, mutate func(doc Document) Document) error {
+	sourceKey, err := r.identifierToKey(source, false)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
+	}
+	targetKey, err := r.identifierToKey(target, false)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
+	}
+
+	if err := r.checkEviction(ctx, target, false); err != nil {
+		return err
+	}
 
-	return r.client.Do(ctx, "JSON.SET", key, ".", string(data)).Err()
+	sourceKey, err = r.resolveKey(ctx, sourceKey)
+	if err != nil {
+		return err
+	}
+	exists, err := r.existsWithFallback(ctx, targetKey)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrAlreadyExists
+	}
+
+	// Both paths run atomically, so in cluster mode source and target
+	// need to share a hash slot. A server-side COPY is only safe within an entity
+	// prefix; across prefixes, the target's relationships and index fields apply.
+	sourcePrefix, _ := entityPrefixOf(source)
+	targetPrefix, _ := entityPrefixOf(target)
+	if mutate == nil && sourcePrefix == targetPrefix {
+		copied, err := copyPersistScript.Run(ctx, r.client, []string{sourceKey, targetKey}).Int64()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+		}
+		if copied == 0 {
+			if n, err := r.client.Exists(ctx, sourceKey).Result(); err == nil && n == 0 {
+				return ErrNotFound
+			}
+			return ErrAlreadyExists
+		}
+		return nil
+	}
+	if mutate == nil {
+		mutate = func(doc Document) Document { return doc }
+	}
+
+	err = r.client.Watch(ctx, func(tx *redis.Tx) error {
+		get := redis.NewCmd(ctx, "JSON.GET", sourceKey)
+		_ = tx.Process(ctx, get)
+		data, err := get.Text()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+		}
+
+		raw, err := r.denormalizeIndexFields(source, []byte(data))
+		if err != nil {
+			return err
+		}
+		doc, err := toDocument(raw)
+		if err != nil {
+			return err
+		}
+		if doc = mutate(doc); doc == nil {
+			return fmt.Errorf("%w: mutate returned no document", ErrInvalidInput)
+		}
+		cloned, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+		if err := r.checkReferences(ctx, target, cloned); err != nil {
+			return err
+		}
+		if cloned, err = r.normalizeIndexFields(target, cloned); err != nil {
+			return err
+		}
+
+		var set *redis.Cmd
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			set = pipe.Do(ctx, "JSON.SET", targetKey, ".", string(cloned), "NX")
+			return nil
+		})
+		if err == redis.Nil || set.Err() == redis.Nil {
+			return ErrAlreadyExists
+		}
+		return err
+	}, sourceKey)
+	if err == redis.TxFailedErr {
+		return fmt.Errorf("%w: source changed during clone", ErrOperationFailed)
+	}
+	return err
 }
 
 func (r *RedisRepository) Delete(ctx context.Context, identifier EntityIdentifier) error {
@@ -359,7 +649,7 @@ func (r *RedisRepository) Delete(ctx context.Context, identifier EntityIdentifie
 		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
 
-	result, err := r.client.Del(ctx, key).Result()
+	result, err := r.deleteWithFallback(ctx, key)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
 	}
@@ -401,6 +691,13 @@ func (r *RedisRepository) List(ctx context.Context, pattern string) ([]EntityIde
 			// nuts.L.Debugf("Error getting value for key %s: %v", key, err)
 			continue
 		}
+		if doc, ok := data.(string); ok {
+			raw, err := r.denormalizeIndexFields(identifier, []byte(doc))
+			if err != nil {
+				continue
+			}
+			data = string(raw)
+		}
 		entities = append(entities, data)
 		identifiers = append(identifiers, identifier)
 	}
@@ -463,6 +760,22 @@ func (r *RedisRepository) AcquireLock(ctx context.Context, identifier EntityIden
 	if err != nil {
 		return false, fmt.Errorf("%w: %v", ErrOperationFailed, err)
 	}
+	fallbackLockKey, ok := r.fallbackKey(lockKey)
+	if !acquired || !ok {
+		return acquired, nil
+	}
+
+	// Instances still running with the old prefix only know the legacy lock key,
+	// so it has to be held as well for the lock to exclude them.
+	acquired, err = r.client.SetNX(ctx, fallbackLockKey, 1, ttl).Result()
+	if err != nil || !acquired {
+		if delErr := r.client.Del(ctx, lockKey).Err(); delErr != nil {
+			r.logger("WARN", fmt.Sprintf("go-datarepository: failed to release lock %s: %v", lockKey, delErr))
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
 	return acquired, nil
 }
 
@@ -472,7 +785,7 @@ func (r *RedisRepository) ReleaseLock(ctx context.Context, identifier EntityIden
 		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
 	lockKey := key + r.separator + KeyPartLock
-	result, err := r.client.Del(ctx, lockKey).Result()
+	result, err := r.deleteWithFallback(ctx, lockKey)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
 	}
@@ -485,7 +798,19 @@ func (r *RedisRepository) ReleaseLock(ctx context.Context, identifier EntityIden
 
 func (r *RedisRepository) Publish(ctx context.Context, channel string, message interface{}) error {
 	fullChannel := r.prefix + r.separator + KeyPartPubSubChannel + r.separator + channel
-	return r.client.Publish(ctx, fullChannel, message).Err()
+	if r.fallbackPrefix == "" {
+		return r.client.Publish(ctx, fullChannel, message).Err()
+	}
+
+	// Also reach subscribers on instances still running with the old prefix
+	fallbackChannel := r.fallbackPrefix + r.fallbackSeparator + KeyPartPubSubChannel + r.fallbackSeparator + channel
+	pipe := r.client.Pipeline()
+	pipe.Publish(ctx, fullChannel, message)
+	if fallbackChannel != fullChannel {
+		pipe.Publish(ctx, fallbackChannel, message)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 func (r *RedisRepository) Subscribe(ctx context.Context, channel string) (chan interface{}, error) {
@@ -516,6 +841,12 @@ func (r *RedisRepository) SetExpiration(ctx context.Context, identifier EntityId
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
+	if err := r.checkEviction(ctx, identifier, true); err != nil {
+		return err
+	}
+	if err := r.adoptFallback(ctx, key); err != nil {
+		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
 	return r.client.Expire(ctx, key, expiration).Err()
 }
 
@@ -524,6 +855,9 @@ func (r *RedisRepository) GetExpiration(ctx context.Context, identifier EntityId
 	if err != nil {
 		return time.Duration(0), fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
+	if key, err = r.resolveKey(ctx, key); err != nil {
+		return 0, err
+	}
 	ttl, err := r.client.TTL(ctx, key).Result()
 	if err != nil {
 		return 0, err
@@ -539,5 +873,75 @@ func (r *RedisRepository) AtomicIncrement(ctx context.Context, identifier Entity
 	if err != nil {
 		return 0, fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
 	}
+	if err := r.checkEviction(ctx, identifier, false); err != nil {
+		return 0, err
+	}
+	if err := r.adoptFallback(ctx, key); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
 	return r.client.Incr(ctx, key).Result()
 }
+
+func (r *RedisRepository) SetString(ctx context.Context, identifier EntityIdentifier, value string, ttl time.Duration) error {
+	return r.setPlain(ctx, identifier, value, ttl)
+}
+
+func (r *RedisRepository) GetString(ctx context.Context, identifier EntityIdentifier) (string, error) {
+	return r.getPlain(ctx, identifier)
+}
+
+func (r *RedisRepository) SetBytes(ctx context.Context, identifier EntityIdentifier, value []byte, ttl time.Duration) error {
+	return r.setPlain(ctx, identifier, value, ttl)
+}
+
+func (r *RedisRepository) GetBytes(ctx context.Context, identifier EntityIdentifier) ([]byte, error) {
+	value, err := r.getPlain(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+func (r *RedisRepository) setPlain(ctx context.Context, identifier EntityIdentifier, value interface{}, ttl time.Duration) error {
+	key, err := r.identifierToKey(identifier, false)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
+	}
+	if ttl < 0 {
+		return fmt.Errorf("%w: ttl must not be negative", ErrInvalidInput)
+	}
+	if err := r.checkEviction(ctx, identifier, ttl > 0); err != nil {
+		return err
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.Set(ctx, key, value, ttl)
+	if fallbackKey, ok := r.fallbackKey(key); ok {
+		pipe.Del(ctx, fallbackKey)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
+	return nil
+}
+
+func (r *RedisRepository) getPlain(ctx context.Context, identifier EntityIdentifier) (string, error) {
+	key, err := r.identifierToKey(identifier, false)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
+	}
+
+	value, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		if fallbackKey, ok := r.fallbackKey(key); ok {
+			value, err = r.client.Get(ctx, fallbackKey).Result()
+		}
+	}
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
+	return value, nil
+}
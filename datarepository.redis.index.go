@@ -0,0 +1,244 @@
+// datarepository.redis.index.go
+
+package datarepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IndexFieldType is the type of a field in the search index
+type IndexFieldType string
+
+const (
+	IndexFieldText    IndexFieldType = "TEXT"
+	IndexFieldTag     IndexFieldType = "TAG"
+	IndexFieldNumeric IndexFieldType = "NUMERIC"
+	// IndexFieldTimestamp holds a time.Time. It is stored as epoch milliseconds and indexed as NUMERIC.
+	IndexFieldTimestamp IndexFieldType = "TIMESTAMP"
+	// IndexFieldDuration holds a time.Duration. It is stored as milliseconds and indexed as NUMERIC.
+	IndexFieldDuration IndexFieldType = "DURATION"
+)
+
+var (
+	indexFieldPathRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*$`)
+)
+
+// IndexField describes a document field included in the search index
+type IndexField struct {
+	// Path is the field's location in the document, with nested fields separated by dots
+	Path string
+	// Name is the attribute used in queries. Defaults to Path with dots replaced by underscores.
+	Name     string
+	Type     IndexFieldType
+	Sortable bool
+}
+
+func (f IndexField) attributeName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return strings.ReplaceAll(f.Path, ".", "_")
+}
+
+func (f IndexField) schemaType() string {
+	switch f.Type {
+	case IndexFieldTimestamp, IndexFieldDuration:
+		return string(IndexFieldNumeric)
+	default:
+		return string(f.Type)
+	}
+}
+
+func (f IndexField) isTemporal() bool {
+	return f.Type == IndexFieldTimestamp || f.Type == IndexFieldDuration
+}
+
+// RegisterIndexFields declares the indexed fields of an entity prefix. Timestamp and duration
+// fields are normalized to milliseconds when written and converted back when read, so that
+// range queries and SORTBY compare them numerically.
+func (r *RedisRepository) RegisterIndexFields(entityPrefix string, fields ...IndexField) error {
+	if err := r.validateEntityPrefix(entityPrefix); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	for _, field := range fields {
+		if !indexFieldPathRegex.MatchString(field.Path) {
+			return fmt.Errorf("%w: invalid index field path %q", ErrInvalidInput, field.Path)
+		}
+		switch field.Type {
+		case IndexFieldText, IndexFieldTag, IndexFieldNumeric, IndexFieldTimestamp, IndexFieldDuration:
+		default:
+			return fmt.Errorf("%w: unsupported index field type %q", ErrInvalidInput, field.Type)
+		}
+	}
+
+	if r.indexFields == nil {
+		r.indexFields = make(map[string][]IndexField)
+	}
+	r.indexFields[entityPrefix] = append(r.indexFields[entityPrefix], fields...)
+	return nil
+}
+
+// CreateIndex creates the search index used by Search over all registered index fields.
+// Returns ErrAlreadyExists if the index already exists.
+func (r *RedisRepository) CreateIndex(ctx context.Context) error {
+	entityPrefixes := make([]string, 0, len(r.indexFields))
+	for entityPrefix := range r.indexFields {
+		entityPrefixes = append(entityPrefixes, entityPrefix)
+	}
+	sort.Strings(entityPrefixes)
+
+	args := []interface{}{"FT.CREATE", r.prefix, "ON", "JSON", "PREFIX", 1, r.prefix + r.separator, "SCHEMA"}
+	seen := make(map[string]IndexField)
+	for _, entityPrefix := range entityPrefixes {
+		for _, field := range r.indexFields[entityPrefix] {
+			name := field.attributeName()
+			if existing, ok := seen[name]; ok {
+				if existing.Path != field.Path || existing.schemaType() != field.schemaType() {
+					return fmt.Errorf("%w: index field %q is registered with conflicting definitions", ErrInvalidInput, name)
+				}
+				continue
+			}
+			seen[name] = field
+			args = append(args, "$."+field.Path, "AS", name, field.schemaType())
+			if field.Sortable {
+				args = append(args, "SORTABLE")
+			}
+		}
+	}
+	if len(seen) == 0 {
+		return fmt.Errorf("%w: no index fields registered", ErrInvalidInput)
+	}
+
+	err := r.client.Do(ctx, args...).Err()
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "index already exists") {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
+	return nil
+}
+
+// TimestampRangeQuery returns a query clause matching timestamps of field between from and to.
+// A zero from or to leaves that side of the range open.
+func TimestampRangeQuery(field string, from, to time.Time) string {
+	lower, upper := "-inf", "+inf"
+	if !from.IsZero() {
+		lower = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		upper = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+	return fmt.Sprintf("@%s:[%s %s]", field, lower, upper)
+}
+
+// DurationRangeQuery returns a query clause matching durations of field between min and max
+func DurationRangeQuery(field string, min, max time.Duration) string {
+	return fmt.Sprintf("@%s:[%d %d]", field, min.Milliseconds(), max.Milliseconds())
+}
+
+// temporalIndexFields returns the timestamp and duration fields of the identifier's entity prefix
+func (r *RedisRepository) temporalIndexFields(identifier EntityIdentifier) []IndexField {
+	entityPrefix, ok := entityPrefixOf(identifier)
+	if !ok {
+		return nil
+	}
+	var fields []IndexField
+	for _, field := range r.indexFields[entityPrefix] {
+		if field.isTemporal() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// normalizeIndexFields converts the timestamp and duration fields of a document about to be
+// written to milliseconds. Only the JSON encodings of time.Time and time.Duration are accepted,
+// as those are the shapes denormalizeIndexFields restores on read.
+func (r *RedisRepository) normalizeIndexFields(identifier EntityIdentifier, data []byte) ([]byte, error) {
+	return r.transformIndexFields(identifier, data, func(field IndexField, value interface{}) (interface{}, error) {
+		if field.Type == IndexFieldTimestamp {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: field %s must be an RFC 3339 timestamp", ErrInvalidInput, field.Path)
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: field %s is not an RFC 3339 timestamp", ErrInvalidInput, field.Path)
+			}
+			return t.UnixMilli(), nil
+		}
+
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%w: field %s must be a duration in nanoseconds", ErrInvalidInput, field.Path)
+		}
+		ns, err := number.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %s is not a duration", ErrInvalidInput, field.Path)
+		}
+		return time.Duration(ns).Milliseconds(), nil
+	})
+}
+
+// denormalizeIndexFields converts the timestamp and duration fields of a stored document back
+// to the JSON encoding of time.Time (RFC 3339, UTC) and time.Duration (nanoseconds).
+func (r *RedisRepository) denormalizeIndexFields(identifier EntityIdentifier, data []byte) ([]byte, error) {
+	return r.transformIndexFields(identifier, data, func(field IndexField, value interface{}) (interface{}, error) {
+		number, ok := value.(json.Number)
+		if !ok {
+			return value, nil
+		}
+		ms, err := number.Int64()
+		if err != nil {
+			return value, nil
+		}
+		if field.Type == IndexFieldTimestamp {
+			return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano), nil
+		}
+		return int64(time.Duration(ms) * time.Millisecond), nil
+	})
+}
+
+func (r *RedisRepository) transformIndexFields(identifier EntityIdentifier, data []byte, transform func(field IndexField, value interface{}) (interface{}, error)) ([]byte, error) {
+	fields := r.temporalIndexFields(identifier)
+	if len(fields) == 0 {
+		return data, nil
+	}
+	doc, err := toDocument(data)
+	if err != nil {
+		return data, nil // Only JSON objects carry index fields
+	}
+
+	for _, field := range fields {
+		parent := map[string]interface{}(doc)
+		parts := strings.Split(field.Path, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := parent[part].(map[string]interface{})
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = next
+		}
+		if parent == nil {
+			continue
+		}
+		name := parts[len(parts)-1]
+		value, ok := parent[name]
+		if !ok || value == nil {
+			continue
+		}
+		if parent[name], err = transform(field, value); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(doc)
+}
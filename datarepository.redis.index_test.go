@@ -0,0 +1,125 @@
+// datarepository.redis.index_test.go
+
+package datarepository
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newIndexTestRepository() *RedisRepository {
+	return &RedisRepository{indexFields: map[string][]IndexField{
+		"event": {
+			{Path: "name", Type: IndexFieldText},
+			{Path: "meta.at", Type: IndexFieldTimestamp},
+			{Path: "timeout", Type: IndexFieldDuration},
+		},
+	}}
+}
+
+func TestNormalizeIndexFields(t *testing.T) {
+	repo := newIndexTestRepository()
+	event := RedisIdentifier{EntityPrefix: "event", ID: "1"}
+
+	tests := []struct {
+		name       string
+		identifier EntityIdentifier
+		data       string
+		want       string
+		wantErr    error
+	}{
+		{"timestamp and duration", event, `{"name":"a","meta":{"at":"2023-11-14T22:13:20.123456Z"},"timeout":1500000000}`, `{"meta":{"at":1700000000123},"name":"a","timeout":1500}`, nil},
+		{"time zone", event, `{"meta":{"at":"2023-11-15T00:13:20+02:00"}}`, `{"meta":{"at":1700000000000}}`, nil},
+		{"missing and null fields", event, `{"meta":{"at":null},"name":"a"}`, `{"meta":{"at":null},"name":"a"}`, nil},
+		{"missing parent", event, `{"meta":"x","timeout":0}`, `{"meta":"x","timeout":0}`, nil},
+		{"other entity prefix", RedisIdentifier{EntityPrefix: "user", ID: "1"}, `{"timeout":"1s"}`, `{"timeout":"1s"}`, nil},
+		{"not an object", event, `"plain"`, `"plain"`, nil},
+		{"numeric timestamp", event, `{"meta":{"at":1700000000000}}`, "", ErrInvalidInput},
+		{"invalid timestamp", event, `{"meta":{"at":"yesterday"}}`, "", ErrInvalidInput},
+		{"string duration", event, `{"timeout":"1.5s"}`, "", ErrInvalidInput},
+		{"fractional duration", event, `{"timeout":1.5}`, "", ErrInvalidInput},
+		{"boolean timestamp", event, `{"meta":{"at":true}}`, "", ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.normalizeIndexFields(tt.identifier, []byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("normalizeIndexFields = %s, %v; want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("normalizeIndexFields = %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDenormalizeIndexFields(t *testing.T) {
+	repo := newIndexTestRepository()
+	event := RedisIdentifier{EntityPrefix: "event", ID: "1"}
+
+	tests := []struct {
+		name       string
+		identifier EntityIdentifier
+		data       string
+		want       string
+	}{
+		{"timestamp and duration", event, `{"meta":{"at":1700000000123},"timeout":1500}`, `{"meta":{"at":"2023-11-14T22:13:20.123Z"},"timeout":1500000000}`},
+		{"already denormalized", event, `{"meta":{"at":"2023-11-14T22:13:20Z"}}`, `{"meta":{"at":"2023-11-14T22:13:20Z"}}`},
+		{"fractional number", event, `{"timeout":1.5}`, `{"timeout":1.5}`},
+		{"other entity prefix", RedisIdentifier{EntityPrefix: "user", ID: "1"}, `{"timeout":1500}`, `{"timeout":1500}`},
+		{"not an object", event, `[1,2]`, `[1,2]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.denormalizeIndexFields(tt.identifier, []byte(tt.data))
+			if err != nil || string(got) != tt.want {
+				t.Errorf("denormalizeIndexFields = %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexFieldsRoundTrip(t *testing.T) {
+	type meta struct {
+		At time.Time `json:"at"`
+	}
+	type eventDoc struct {
+		Name    string        `json:"name"`
+		Meta    meta          `json:"meta"`
+		Timeout time.Duration `json:"timeout"`
+	}
+	repo := newIndexTestRepository()
+	event := RedisIdentifier{EntityPrefix: "event", ID: "1"}
+	zone := time.FixedZone("CEST", 2*60*60)
+	in := eventDoc{Name: "a", Meta: meta{At: time.Date(2023, 11, 15, 0, 13, 20, 123456789, zone)}, Timeout: 1500*time.Millisecond + 42}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	stored, err := repo.normalizeIndexFields(event, data)
+	if err != nil {
+		t.Fatalf("normalizeIndexFields: %v", err)
+	}
+	restored, err := repo.denormalizeIndexFields(event, stored)
+	if err != nil {
+		t.Fatalf("denormalizeIndexFields: %v", err)
+	}
+	var out eventDoc
+	if err := json.Unmarshal(restored, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	// Sub-millisecond precision and the time zone are lost
+	if want := in.Meta.At.Truncate(time.Millisecond).UTC(); !out.Meta.At.Equal(want) || out.Meta.At.Location() != time.UTC {
+		t.Errorf("at = %v, want %v", out.Meta.At, want)
+	}
+	if want := in.Timeout.Truncate(time.Millisecond); out.Timeout != want {
+		t.Errorf("timeout = %v, want %v", out.Timeout, want)
+	}
+}
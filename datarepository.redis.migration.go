@@ -0,0 +1,282 @@
+// datarepository.redis.migration.go
+
+package datarepository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultMigrationBatchSize = 100
+)
+
+// MigratePrefixOptions controls how MigratePrefix moves keys between prefixes
+type MigratePrefixOptions struct {
+	// OldSeparator is the separator used by keys under the old prefix.
+	// Defaults to the repository's fallback separator.
+	OldSeparator string
+	// NewSeparator is the separator used by keys under the new prefix.
+	// Defaults to the repository's key separator.
+	NewSeparator string
+	// BatchSize is the COUNT hint passed to SCAN. Defaults to DefaultMigrationBatchSize.
+	BatchSize int64
+	// Overwrite replaces keys that already exist under the new prefix.
+	// Without it those keys are skipped and their source is left untouched.
+	Overwrite bool
+	// DeleteSource removes the old key after it has been copied.
+	DeleteSource bool
+	// DryRun only counts the keys that would be migrated.
+	DryRun bool
+}
+
+// MigratePrefixResult summarizes a MigratePrefix run
+type MigratePrefixResult struct {
+	Scanned  int
+	Migrated int
+	Skipped  int
+}
+
+// MigratePrefix copies every key stored under oldPrefix to the same key under newPrefix,
+// preserving values and TTLs. It can be run repeatedly while the application is live:
+// combined with RedisConfig.FallbackKeyPrefix, reads keep working for keys that have not
+// been migrated yet. Keys that fail to migrate are reported in a *BatchError, identified
+// by their full old key.
+func (r *RedisRepository) MigratePrefix(ctx context.Context, oldPrefix, newPrefix string, opts MigratePrefixOptions) (MigratePrefixResult, error) {
+	result := MigratePrefixResult{}
+
+	if opts.OldSeparator == "" {
+		opts.OldSeparator = r.fallbackSeparator
+	}
+	if opts.NewSeparator == "" {
+		opts.NewSeparator = r.separator
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultMigrationBatchSize
+	}
+	if !validKeyRegex.MatchString(oldPrefix) || !validKeyRegex.MatchString(newPrefix) {
+		return result, fmt.Errorf("%w: %v", ErrInvalidInput, ErrInvalidKeyChars)
+	}
+	if oldPrefix == newPrefix && opts.OldSeparator == opts.NewSeparator {
+		return result, fmt.Errorf("%w: old and new prefix are identical", ErrInvalidInput)
+	}
+
+	failures := &BatchError{Operation: "MigratePrefix"}
+	err := r.scanKeys(ctx, oldPrefix+opts.OldSeparator+"*", opts.BatchSize, func(oldKey string) error {
+		result.Scanned++
+		newKey := rekey(oldKey, oldPrefix, opts.OldSeparator, newPrefix, opts.NewSeparator)
+		if len(newKey) > MaxKeyLength || !validKeyRegex.MatchString(newKey) {
+			result.Skipped++
+			return nil
+		}
+		if opts.DryRun {
+			exists, err := r.client.Exists(ctx, newKey).Result()
+			if err != nil {
+				failures.add(SimpleIdentifier(oldKey), fmt.Errorf("%w: %w", ErrOperationFailed, err))
+			} else if exists > 0 && !opts.Overwrite {
+				result.Skipped++
+			} else {
+				result.Migrated++
+			}
+			return nil
+		}
+
+		migrated, err := r.migrateKey(ctx, oldKey, newKey, opts)
+		if err != nil {
+			failures.add(SimpleIdentifier(oldKey), fmt.Errorf("%w: %w", ErrOperationFailed, err))
+			return nil
+		}
+		if migrated {
+			result.Migrated++
+		} else {
+			result.Skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, failures.errOrNil()
+}
+
+func (r *RedisRepository) migrateKey(ctx context.Context, oldKey, newKey string, opts MigratePrefixOptions) (bool, error) {
+	// Read payload and TTL atomically, so a key expiring in between is not restored without expiry
+	var dump *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		dump = pipe.Dump(ctx, oldKey)
+		pttl = pipe.PTTL(ctx, oldKey)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return false, err
+	}
+	payload, err := dump.Result()
+	if err == redis.Nil {
+		return false, nil // expired or deleted since it was scanned
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ttl := pttl.Val()
+	switch {
+	case ttl == -1:
+		ttl = 0 // no expiration
+	case ttl <= 0:
+		return false, nil // expired since it was scanned
+	}
+
+	if opts.Overwrite {
+		err = r.client.RestoreReplace(ctx, newKey, ttl, payload).Err()
+	} else {
+		err = r.client.Restore(ctx, newKey, ttl, payload).Err()
+		if err != nil && strings.HasPrefix(err.Error(), "BUSYKEY") {
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if opts.DeleteSource {
+		if err := r.client.Del(ctx, oldKey).Err(); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// scanKeys calls fn for every key matching pattern. On a cluster every master is scanned;
+// calls to fn are serialized either way.
+func (r *RedisRepository) scanKeys(ctx context.Context, pattern string, count int64, fn func(key string) error) error {
+	var mu sync.Mutex
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, pattern, count).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			err := fn(iter.Val())
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrOperationFailed, err)
+		}
+		return nil
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	}
+	return scan(ctx, r.client)
+}
+
+// rekey moves key from oldPrefix/oldSeparator to newPrefix/newSeparator
+func rekey(key, oldPrefix, oldSeparator, newPrefix, newSeparator string) string {
+	suffix := strings.TrimPrefix(key, oldPrefix+oldSeparator)
+	parts := strings.Split(suffix, oldSeparator)
+	return newPrefix + newSeparator + strings.Join(parts, newSeparator)
+}
+
+// fallbackKey returns the dual-read counterpart of key, if dual-read mode is enabled
+func (r *RedisRepository) fallbackKey(key string) (string, bool) {
+	if r.fallbackPrefix == "" {
+		return "", false
+	}
+	fallback := rekey(key, r.prefix, r.separator, r.fallbackPrefix, r.fallbackSeparator)
+	if fallback == key {
+		return "", false
+	}
+	return fallback, true
+}
+
+func (r *RedisRepository) existsWithFallback(ctx context.Context, key string) (bool, error) {
+	fallbackKey, ok := r.fallbackKey(key)
+	if !ok {
+		exists, err := r.client.Exists(ctx, key).Result()
+		return exists > 0, err
+	}
+
+	// Keys may live in different cluster slots, so check them separately
+	pipe := r.client.Pipeline()
+	current := pipe.Exists(ctx, key)
+	legacy := pipe.Exists(ctx, fallbackKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return current.Val()+legacy.Val() > 0, nil
+}
+
+// resolveKey returns the key an entity is currently stored under: key itself, or its
+// fallback counterpart if only that exists.
+func (r *RedisRepository) resolveKey(ctx context.Context, key string) (string, error) {
+	fallbackKey, ok := r.fallbackKey(key)
+	if !ok {
+		return key, nil
+	}
+
+	pipe := r.client.Pipeline()
+	current := pipe.Exists(ctx, key)
+	legacy := pipe.Exists(ctx, fallbackKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	if current.Val() == 0 && legacy.Val() > 0 {
+		return fallbackKey, nil
+	}
+	return key, nil
+}
+
+// adoptFallback moves an entity that only exists under the fallback key to key, keeping its
+// TTL, so that commands operating on key in place (INCR, EXPIRE, JSON.SET) see its state.
+func (r *RedisRepository) adoptFallback(ctx context.Context, key string) error {
+	fallbackKey, ok := r.fallbackKey(key)
+	if !ok {
+		return nil
+	}
+	resolved, err := r.resolveKey(ctx, key)
+	if err != nil || resolved == key {
+		return err
+	}
+	_, err = r.migrateKey(ctx, fallbackKey, key, MigratePrefixOptions{DeleteSource: true})
+	return err
+}
+
+func (r *RedisRepository) deleteWithFallback(ctx context.Context, key string) (int64, error) {
+	fallbackKey, ok := r.fallbackKey(key)
+	if !ok {
+		return r.client.Del(ctx, key).Result()
+	}
+
+	pipe := r.client.Pipeline()
+	current := pipe.Del(ctx, key)
+	legacy := pipe.Del(ctx, fallbackKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return current.Val() + legacy.Val(), nil
+}
+
+// setJSON writes data to key. In dual-read mode the legacy copy is removed so that
+// writes always land under the new prefix.
+func (r *RedisRepository) setJSON(ctx context.Context, key string, data []byte) error {
+	fallbackKey, ok := r.fallbackKey(key)
+	if !ok {
+		return r.client.Do(ctx, "JSON.SET", key, ".", string(data)).Err()
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.Do(ctx, "JSON.SET", key, ".", string(data))
+	pipe.Del(ctx, fallbackKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,127 @@
+// datarepository.redis.relationships.go
+
+package datarepository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func (r *RedisRepository) CheckIntegrity(ctx context.Context, opts IntegrityCheckOptions) (IntegrityReport, error) {
+	report := IntegrityReport{}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultMigrationBatchSize
+	}
+
+	parents := make(map[string]bool)
+	failures := &BatchError{Operation: "CheckIntegrity"}
+	for _, relationship := range r.relationshipsFor(opts.ChildPrefixes) {
+		pattern := r.prefix + r.separator + relationship.ChildPrefix + r.separator + "*"
+		err := r.scanKeys(ctx, pattern, opts.BatchSize, func(key string) error {
+			parts, err := r.parseKey(key)
+			if err != nil || len(parts) != 2 {
+				return nil // Skip locks and other auxiliary keys
+			}
+			child := RedisIdentifier{EntityPrefix: parts[0], ID: parts[1]}
+			data, err := r.client.Do(ctx, "JSON.GET", key).Text()
+			if err == redis.Nil || (err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")) {
+				return nil // Skip keys that vanished or are not JSON documents
+			}
+			if err != nil {
+				failures.add(child, fmt.Errorf("%w: %w", ErrOperationFailed, err))
+				return nil
+			}
+			report.Checked++
+
+			parentID, ok := referenceID([]byte(data), relationship)
+			if !ok {
+				return nil
+			}
+			exists, err := r.parentExists(ctx, relationship, parentID, parents)
+			if err != nil {
+				failures.add(child, err)
+				return nil
+			}
+			if exists {
+				return nil
+			}
+
+			report.Dangling = append(report.Dangling, DanglingReference{Child: child, Relationship: relationship, ParentID: parentID})
+			repaired, err := r.repairReference(ctx, key, relationship, opts.Repair)
+			if err != nil {
+				failures.add(child, fmt.Errorf("%w: %w", ErrOperationFailed, err))
+				return nil
+			}
+			if repaired {
+				report.Repaired++
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, failures.errOrNil()
+}
+
+// parentExists checks whether the parent referenced through relationship exists.
+// Results are memoized in cache for the duration of a single check.
+func (r *RedisRepository) parentExists(ctx context.Context, relationship Relationship, parentID string, cache map[string]bool) (bool, error) {
+	parentKey, err := r.identifierToKey(RedisIdentifier{EntityPrefix: relationship.ParentPrefix, ID: parentID}, false)
+	if err != nil {
+		return false, nil // An ID that cannot form a valid key can never exist
+	}
+	if exists, ok := cache[parentKey]; ok {
+		return exists, nil
+	}
+	exists, err := r.existsWithFallback(ctx, parentKey)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrOperationFailed, err)
+	}
+	if cache != nil {
+		cache[parentKey] = exists
+	}
+	return exists, nil
+}
+
+func (r *RedisRepository) repairReference(ctx context.Context, key string, relationship Relationship, repair IntegrityRepair) (bool, error) {
+	switch repair {
+	case IntegrityRepairDeleteChild:
+		return true, r.client.Del(ctx, key).Err()
+	case IntegrityRepairClearReference:
+		err := r.client.Do(ctx, "JSON.DEL", key, "$."+relationship.Field).Err()
+		if err == redis.Nil {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, nil
+	}
+}
+
+// checkReferences returns ErrDanglingReference if data, about to be written for identifier,
+// references a missing parent through an enforced relationship.
+func (r *RedisRepository) checkReferences(ctx context.Context, identifier EntityIdentifier, data []byte) error {
+	childPrefix, ok := entityPrefixOf(identifier)
+	if !ok {
+		return nil
+	}
+	for _, relationship := range r.enforcedRelationships(childPrefix) {
+		parentID, ok := referenceID(data, relationship)
+		if !ok {
+			continue
+		}
+		exists, err := r.parentExists(ctx, relationship, parentID, nil)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s %q", ErrDanglingReference, relationship.ParentPrefix, parentID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// datarepository.redis.sampler.go
+
+package datarepository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultSamplerCapacity     = 1000
+	DefaultSamplerMaxArgLength = 128
+	redactedArg                = "[redacted]"
+)
+
+// valueArgIndex is the position of the stored value in the arguments of common write
+// commands, counting the command name as 0. Used to redact values but keep keys.
+var valueArgIndex = map[string]int{
+	"json.set": 3,
+	"set":      2,
+	"setnx":    2,
+	"setex":    3,
+	"psetex":   3,
+	"getset":   2,
+	"publish":  2,
+	"restore":  3,
+}
+
+// CommandSamplerConfig enables recording a sample of the commands sent to Redis
+type CommandSamplerConfig struct {
+	// Rate is the fraction of commands recorded, from 0 to 1
+	Rate float64
+	// Capacity is the number of most recent samples kept. Defaults to DefaultSamplerCapacity.
+	Capacity int
+	// MaxArgLength truncates every argument to this many bytes. Defaults to DefaultSamplerMaxArgLength.
+	MaxArgLength int
+	// RecordValues records the values of write commands, which are otherwise replaced with a
+	// placeholder while keys and paths are kept. Credentials passed to AUTH and HELLO are always redacted.
+	RecordValues bool
+}
+
+// SampledCommand is a command recorded by the command sampler
+type SampledCommand struct {
+	Time      time.Time     `json:"time"`
+	Name      string        `json:"name"`
+	Args      []string      `json:"args"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+	Pipelined bool          `json:"pipelined,omitempty"`
+}
+
+// commandSampler is a go-redis hook recording a fraction of all commands into a ring buffer
+type commandSampler struct {
+	config  CommandSamplerConfig
+	rate    atomic.Uint64 // math.Float64bits of the current rate
+	mu      sync.Mutex
+	samples []SampledCommand
+	next    int
+	full    bool
+}
+
+func newCommandSampler(config CommandSamplerConfig) (*commandSampler, error) {
+	if !validSampleRate(config.Rate) {
+		return nil, fmt.Errorf("%w: command sample rate must be between 0 and 1", ErrInvalidInput)
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = DefaultSamplerCapacity
+	}
+	if config.MaxArgLength <= 0 {
+		config.MaxArgLength = DefaultSamplerMaxArgLength
+	}
+	sampler := &commandSampler{
+		config:  config,
+		samples: make([]SampledCommand, config.Capacity),
+	}
+	sampler.rate.Store(math.Float64bits(config.Rate))
+	return sampler, nil
+}
+
+func validSampleRate(rate float64) bool {
+	return !math.IsNaN(rate) && rate >= 0 && rate <= 1
+}
+
+func (s *commandSampler) sample() bool {
+	rate := math.Float64frombits(s.rate.Load())
+	return rate > 0 && rand.Float64() < rate
+}
+
+func (s *commandSampler) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (s *commandSampler) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !s.sample() {
+			return next(ctx, cmd)
+		}
+		start := time.Now()
+		err := next(ctx, cmd)
+		s.record(cmd, err, start, time.Since(start), false)
+		return err
+	}
+}
+
+func (s *commandSampler) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		sampled := make([]redis.Cmder, 0, len(cmds))
+		for _, cmd := range cmds {
+			if s.sample() {
+				sampled = append(sampled, cmd)
+			}
+		}
+		if len(sampled) == 0 {
+			return next(ctx, cmds)
+		}
+		start := time.Now()
+		err := next(ctx, cmds)
+		duration := time.Since(start)
+		for _, cmd := range sampled {
+			s.record(cmd, err, start, duration, true)
+		}
+		return err
+	}
+}
+
+// record stores cmd as a sample. err is the error returned for the command or its pipeline;
+// an error set on the command itself takes precedence.
+func (s *commandSampler) record(cmd redis.Cmder, err error, start time.Time, duration time.Duration, pipelined bool) {
+	sample := SampledCommand{
+		Time:      start,
+		Name:      strings.ToLower(cmd.Name()),
+		Args:      s.formatArgs(cmd.Args()),
+		Duration:  duration,
+		Pipelined: pipelined,
+	}
+	if cmdErr := cmd.Err(); cmdErr != nil {
+		err = cmdErr
+	}
+	if err != nil {
+		sample.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// formatArgs renders the arguments after the command name, redacted and truncated
+func (s *commandSampler) formatArgs(args []interface{}) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	name := strings.ToLower(fmt.Sprint(args[0]))
+	formatted := make([]string, 0, len(args)-1)
+	for i := 1; i < len(args); i++ {
+		var arg string
+		switch v := args[i].(type) {
+		case string:
+			arg = v
+		case []byte:
+			arg = string(v)
+		default:
+			arg = fmt.Sprint(v)
+		}
+
+		switch {
+		case name == "auth":
+			arg = redactedArg
+		case name == "hello" && i >= 3 && strings.EqualFold(fmt.Sprint(args[i-1]), "auth"):
+			arg = redactedArg // username
+		case name == "hello" && i >= 4 && strings.EqualFold(fmt.Sprint(args[i-2]), "auth"):
+			arg = redactedArg // password
+		case !s.config.RecordValues && valueArgIndex[name] == i:
+			arg = redactedArg
+		case len(arg) > s.config.MaxArgLength:
+			arg = fmt.Sprintf("%s...(%d bytes)", arg[:s.config.MaxArgLength], len(arg))
+		}
+		formatted = append(formatted, arg)
+	}
+	return formatted
+}
+
+// snapshot returns the recorded samples, oldest first
+func (s *commandSampler) snapshot() []SampledCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.full {
+		return append([]SampledCommand(nil), s.samples[:s.next]...)
+	}
+	snapshot := make([]SampledCommand, 0, len(s.samples))
+	snapshot = append(snapshot, s.samples[s.next:]...)
+	return append(snapshot, s.samples[:s.next]...)
+}
+
+// SampledCommands returns the commands recorded by the command sampler, oldest first.
+// Returns nil if command sampling is not enabled.
+func (r *RedisRepository) SampledCommands() []SampledCommand {
+	if r.sampler == nil {
+		return nil
+	}
+	return r.sampler.snapshot()
+}
+
+// SetCommandSampleRate changes the fraction of commands recorded, e.g. to raise it during an incident.
+// Returns ErrNotSupported if command sampling was not enabled in the configuration.
+func (r *RedisRepository) SetCommandSampleRate(rate float64) error {
+	if r.sampler == nil {
+		return fmt.Errorf("%w: command sampling is not enabled", ErrNotSupported)
+	}
+	if !validSampleRate(rate) {
+		return fmt.Errorf("%w: command sample rate must be between 0 and 1", ErrInvalidInput)
+	}
+	r.sampler.rate.Store(math.Float64bits(rate))
+	return nil
+}
+
+// CommandSamplerHandler returns an http.Handler serving the sampled commands as JSON,
+// meant to be mounted on the application's debug endpoint.
+func (r *RedisRepository) CommandSamplerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.sampler == nil {
+			http.Error(w, "command sampling is not enabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.SampledCommands())
+	})
+}
@@ -0,0 +1,139 @@
+// datarepository.redis.sampler_test.go
+
+package datarepository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestSampler(t *testing.T, config CommandSamplerConfig) *commandSampler {
+	t.Helper()
+	sampler, err := newCommandSampler(config)
+	if err != nil {
+		t.Fatalf("newCommandSampler: %v", err)
+	}
+	return sampler
+}
+
+func TestCommandSamplerFormatArgs(t *testing.T) {
+	long := "0123456789abcdef"
+	tests := []struct {
+		name   string
+		config CommandSamplerConfig
+		args   []interface{}
+		want   []string
+	}{
+		{"auth password", CommandSamplerConfig{RecordValues: true}, []interface{}{"auth", "hunter2"}, []string{redactedArg}},
+		{"auth user and password", CommandSamplerConfig{RecordValues: true}, []interface{}{"AUTH", "admin", "hunter2"}, []string{redactedArg, redactedArg}},
+		{"hello auth", CommandSamplerConfig{RecordValues: true}, []interface{}{"hello", 3, "AUTH", "admin", "hunter2", "SETNAME", "app"}, []string{"3", "AUTH", redactedArg, redactedArg, "SETNAME", "app"}},
+		{"json.set value", CommandSamplerConfig{}, []interface{}{"JSON.SET", "app:user:1", ".", `{"token":"abc"}`}, []string{"app:user:1", ".", redactedArg}},
+		{"set value", CommandSamplerConfig{}, []interface{}{"set", "app:token:1", "abc", "ex", 60}, []string{"app:token:1", redactedArg, "ex", "60"}},
+		{"set bytes", CommandSamplerConfig{}, []interface{}{"set", "app:blob:1", []byte("abc")}, []string{"app:blob:1", redactedArg}},
+		{"restore payload", CommandSamplerConfig{}, []interface{}{"restore", "app:user:1", 0, "\x00payload"}, []string{"app:user:1", "0", redactedArg}},
+		{"publish message", CommandSamplerConfig{}, []interface{}{"publish", "app:channel:news", "hello"}, []string{"app:channel:news", redactedArg}},
+		{"read command", CommandSamplerConfig{}, []interface{}{"get", "app:token:1"}, []string{"app:token:1"}},
+		{"recorded values", CommandSamplerConfig{RecordValues: true}, []interface{}{"set", "app:token:1", "abc"}, []string{"app:token:1", "abc"}},
+		{"truncated key", CommandSamplerConfig{MaxArgLength: 4}, []interface{}{"get", long}, []string{"0123...(16 bytes)"}},
+		{"truncated value", CommandSamplerConfig{MaxArgLength: 4, RecordValues: true}, []interface{}{"set", "k", long}, []string{"k", "0123...(16 bytes)"}},
+		{"redacted before truncated", CommandSamplerConfig{MaxArgLength: 4}, []interface{}{"auth", long}, []string{redactedArg}},
+		{"no arguments", CommandSamplerConfig{}, []interface{}{"ping"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler := newTestSampler(t, tt.config)
+			if got := sampler.formatArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formatArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandSamplerRecord(t *testing.T) {
+	ctx := context.Background()
+	sampler := newTestSampler(t, CommandSamplerConfig{Rate: 1})
+
+	failed := redis.NewStatusCmd(ctx, "set", "app:token:1", "abc")
+	failed.SetErr(errors.New("READONLY"))
+	sampler.record(failed, nil, time.Now(), time.Millisecond, false)
+	sampler.record(redis.NewStringCmd(ctx, "get", "app:token:1"), errors.New("pipeline failed"), time.Now(), time.Millisecond, true)
+	sampler.record(redis.NewStringCmd(ctx, "GET", "app:token:1"), nil, time.Now(), time.Millisecond, false)
+
+	samples := sampler.snapshot()
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+	if got := samples[0]; got.Name != "set" || got.Error != "READONLY" || !reflect.DeepEqual(got.Args, []string{"app:token:1", redactedArg}) {
+		t.Errorf("unexpected sample %+v", got)
+	}
+	if got := samples[1]; got.Error != "pipeline failed" || !got.Pipelined {
+		t.Errorf("unexpected sample %+v", got)
+	}
+	if got := samples[2]; got.Name != "get" || got.Error != "" {
+		t.Errorf("unexpected sample %+v", got)
+	}
+}
+
+func TestCommandSamplerSnapshotWrapsAround(t *testing.T) {
+	ctx := context.Background()
+	sampler := newTestSampler(t, CommandSamplerConfig{Rate: 1, Capacity: 3})
+	keys := func() []string {
+		var keys []string
+		for _, sample := range sampler.snapshot() {
+			keys = append(keys, sample.Args[0])
+		}
+		return keys
+	}
+
+	if got := sampler.snapshot(); len(got) != 0 {
+		t.Errorf("expected no samples, got %v", got)
+	}
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		sampler.record(redis.NewStringCmd(ctx, "get", key), nil, time.Now(), 0, false)
+		if i == 1 {
+			if got := keys(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+				t.Errorf("before wraparound = %v, want [a b]", got)
+			}
+		}
+	}
+	if got := keys(); !reflect.DeepEqual(got, []string{"c", "d", "e"}) {
+		t.Errorf("after wraparound = %v, want [c d e]", got)
+	}
+}
+
+func TestCommandSamplerRates(t *testing.T) {
+	for _, rate := range []float64{math.NaN(), -0.1, 1.1, math.Inf(1)} {
+		if _, err := newCommandSampler(CommandSamplerConfig{Rate: rate}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("newCommandSampler(%v) = %v, want ErrInvalidInput", rate, err)
+		}
+	}
+
+	repo := &RedisRepository{sampler: newTestSampler(t, CommandSamplerConfig{})}
+	if repo.sampler.sample() {
+		t.Error("a zero rate must not sample")
+	}
+	for _, rate := range []float64{math.NaN(), -1, 2} {
+		if err := repo.SetCommandSampleRate(rate); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("SetCommandSampleRate(%v) = %v, want ErrInvalidInput", rate, err)
+		}
+	}
+	if err := repo.SetCommandSampleRate(1); err != nil {
+		t.Fatalf("SetCommandSampleRate(1): %v", err)
+	}
+	if !repo.sampler.sample() {
+		t.Error("a rate of 1 must always sample")
+	}
+
+	if err := (&RedisRepository{}).SetCommandSampleRate(1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SetCommandSampleRate without sampler = %v, want ErrNotSupported", err)
+	}
+	if got := (&RedisRepository{}).SampledCommands(); got != nil {
+		t.Errorf("SampledCommands without sampler = %v, want nil", got)
+	}
+}
@@ -0,0 +1,136 @@
+// datarepository.relationships.go
+
+package datarepository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrDanglingReference is returned when an entity references a parent that does not exist
+	ErrDanglingReference = errors.New("referenced entity does not exist")
+
+	relationshipFieldRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Relationship declares that entities under ChildPrefix reference an entity under
+// ParentPrefix through the top-level JSON field Field, which holds the parent's ID.
+type Relationship struct {
+	ChildPrefix  string
+	ParentPrefix string
+	Field        string
+	// Enforce rejects writes whose reference points to a parent that does not exist
+	Enforce bool
+}
+
+// IntegrityRepair selects what CheckIntegrity does with dangling references
+type IntegrityRepair int
+
+const (
+	// IntegrityRepairNone only reports dangling references
+	IntegrityRepairNone IntegrityRepair = iota
+	// IntegrityRepairDeleteChild deletes entities with a dangling reference
+	IntegrityRepairDeleteChild
+	// IntegrityRepairClearReference removes the dangling reference field from the entity
+	IntegrityRepairClearReference
+)
+
+// IntegrityCheckOptions controls a CheckIntegrity run
+type IntegrityCheckOptions struct {
+	// ChildPrefixes limits the check to relationships of these child prefixes. Empty checks all.
+	ChildPrefixes []string
+	Repair        IntegrityRepair
+	// BatchSize is the number of keys fetched per scan step, where applicable
+	BatchSize int64
+}
+
+// DanglingReference is a child entity whose referenced parent does not exist
+type DanglingReference struct {
+	Child        EntityIdentifier
+	Relationship Relationship
+	ParentID     string
+}
+
+// IntegrityReport summarizes a CheckIntegrity run
+type IntegrityReport struct {
+	Checked  int
+	Dangling []DanglingReference
+	Repaired int
+}
+
+// RegisterRelationship adds a relationship used by CheckIntegrity and write-time enforcement
+func (br *BaseRepository) RegisterRelationship(relationship Relationship) error {
+	if !entityPrefixRegex.MatchString(relationship.ChildPrefix) || !entityPrefixRegex.MatchString(relationship.ParentPrefix) {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, ErrInvalidEntityPrefix)
+	}
+	if !relationshipFieldRegex.MatchString(relationship.Field) {
+		return fmt.Errorf("%w: invalid relationship field %q", ErrInvalidInput, relationship.Field)
+	}
+	br.relationships = append(br.relationships, relationship)
+	return nil
+}
+
+// GetRelationships returns all registered relationships
+func (br *BaseRepository) GetRelationships() []Relationship {
+	return append([]Relationship(nil), br.relationships...)
+}
+
+// relationshipsFor returns the registered relationships whose child prefix is selected by childPrefixes
+func (br *BaseRepository) relationshipsFor(childPrefixes []string) []Relationship {
+	if len(childPrefixes) == 0 {
+		return br.GetRelationships()
+	}
+	var selected []Relationship
+	for _, relationship := range br.relationships {
+		for _, prefix := range childPrefixes {
+			if relationship.ChildPrefix == prefix {
+				selected = append(selected, relationship)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// enforcedRelationships returns the enforced relationships of the given child prefix
+func (br *BaseRepository) enforcedRelationships(childPrefix string) []Relationship {
+	var enforced []Relationship
+	for _, relationship := range br.relationships {
+		if relationship.Enforce && relationship.ChildPrefix == childPrefix {
+			enforced = append(enforced, relationship)
+		}
+	}
+	return enforced
+}
+
+// entityPrefixOf returns the entity prefix of an identifier. Identifiers other than
+// RedisIdentifier are split on DefaultKeySeparator.
+func entityPrefixOf(identifier EntityIdentifier) (string, bool) {
+	if id, ok := identifier.(RedisIdentifier); ok {
+		return id.EntityPrefix, true
+	}
+	prefix, _, found := strings.Cut(identifier.String(), DefaultKeySeparator)
+	return prefix, found
+}
+
+// referenceID returns the parent ID stored in the relationship field of a JSON document
+func referenceID(data []byte, relationship Relationship) (string, bool) {
+	doc, err := toDocument(data)
+	if err != nil {
+		return "", false
+	}
+	switch ref := doc[relationship.Field].(type) {
+	case nil:
+		return "", false
+	case string:
+		return ref, ref != ""
+	case json.Number:
+		return ref.String(), true
+	default:
+		return fmt.Sprint(ref), true
+	}
+}
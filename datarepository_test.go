@@ -0,0 +1,75 @@
+// datarepository_test.go
+
+package datarepository
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type causeError struct {
+	code int
+}
+
+func (e *causeError) Error() string {
+	return fmt.Sprintf("cause %d", e.code)
+}
+
+func TestBatchErrorReachesItemCauses(t *testing.T) {
+	failures := &BatchError{Operation: "MigratePrefix"}
+	failures.add(SimpleIdentifier("a"), fmt.Errorf("%w: %w", ErrOperationFailed, &causeError{code: 1}))
+	failures.add(SimpleIdentifier("b"), ErrNotFound)
+	err := fmt.Errorf("migration: %w", failures.errOrNil())
+
+	if !errors.Is(err, ErrOperationFailed) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("errors.Is does not reach the item errors of %v", err)
+	}
+	if errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("errors.Is matched an error no item holds")
+	}
+	var cause *causeError
+	if !errors.As(err, &cause) || cause.code != 1 {
+		t.Errorf("errors.As does not reach the wrapped item cause of %v", err)
+	}
+	var item *BatchItemError
+	if !errors.As(err, &item) || item.Identifier.String() != "a" {
+		t.Errorf("errors.As does not reach the first BatchItemError of %v", err)
+	}
+
+	batchErr, ok := AsBatchError(err)
+	if !ok {
+		t.Fatalf("AsBatchError did not find the BatchError in %v", err)
+	}
+	if got := batchErr.FailedIdentifiers(); !reflect.DeepEqual(got, []EntityIdentifier{SimpleIdentifier("a"), SimpleIdentifier("b")}) {
+		t.Errorf("FailedIdentifiers = %v", got)
+	}
+}
+
+func TestBatchErrorMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []*BatchItemError
+		want     string
+	}{
+		{"empty", nil, "Import: no items failed"},
+		{"single", []*BatchItemError{{Identifier: SimpleIdentifier("a"), Err: ErrNotFound}}, "Import: 1 item failed: a: " + ErrNotFound.Error()},
+		{"many", []*BatchItemError{{Identifier: SimpleIdentifier("a"), Err: ErrNotFound}, {Identifier: SimpleIdentifier("b"), Err: ErrNotFound}}, "Import: 2 items failed, first: a: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &BatchError{Operation: "Import", Failures: tt.failures}
+			if got := err.Error(); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("Error() = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchErrorOrNil(t *testing.T) {
+	if err := (&BatchError{Operation: "Import"}).errOrNil(); err != nil {
+		t.Errorf("errOrNil without failures = %v, want nil", err)
+	}
+}